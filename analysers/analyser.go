@@ -0,0 +1,153 @@
+package analysers
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+)
+
+// Analyser examines a scenario and produces a diagnostic report
+type Analyser interface {
+	// Name of the analyser
+	Name() string
+	// Analyse the scenario
+	Analyse(scenario *scenarios.Scenario) Report
+}
+
+// Report is the result of an analysis
+type Report interface {
+	// String returns the report formatted for display
+	String() string
+}
+
+// Cause is a likely cause of a fault, Score is used to rank the causes
+type Cause struct {
+	Description string
+	Score       float64
+}
+
+// String formats the cause for display
+func (cause Cause) String() string {
+	return fmt.Sprintf("%5.1f %s", cause.Score, cause.Description)
+}
+
+// All returns the analysers that are run on every log
+func All() []Analyser {
+	return []Analyser{
+		NewFuelTrimAnalyser(),
+		NewCrankingAnalyser(),
+		NewChargingAnalyser(),
+	}
+}
+
+// checks returns the analysers that need a log recorded for the check,
+// these are only run when requested by name
+func checks() []Analyser {
+	return []Analyser{
+		NewThrottleAnalyser(),
+	}
+}
+
+// Find returns the analyser with the given name, nil if there isn't one
+func Find(name string) Analyser {
+	for _, analyser := range append(All(), checks()...) {
+		if analyser.Name() == name {
+			return analyser
+		}
+	}
+
+	return nil
+}
+
+// hasChannels returns true if none of the channels are missing from the dataframe
+func hasChannels(data *scenarios.MemsFCRData, channels ...string) bool {
+	for _, channel := range channels {
+		if !data.HasChannel(channel) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// withChannels returns the dataframes that have all the channels
+func withChannels(memsdata []*scenarios.MemsFCRData, channels ...string) []*scenarios.MemsFCRData {
+	var selected []*scenarios.MemsFCRData
+
+	for _, data := range memsdata {
+		if hasChannels(data, channels...) {
+			selected = append(selected, data)
+		}
+	}
+
+	return selected
+}
+
+// mean calculates the mean of the values, returns 0 if there are no values
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+
+	return sum / float64(len(values))
+}
+
+// minimum returns the smallest value, 0 if there are no values
+func minimum(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	min := math.MaxFloat64
+	for _, v := range values {
+		min = math.Min(min, v)
+	}
+
+	return min
+}
+
+// stddev calculates the population standard deviation of the values
+func stddev(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	m := mean(values)
+
+	var sum float64
+	for _, v := range values {
+		sum += (v - m) * (v - m)
+	}
+
+	return math.Sqrt(sum / float64(len(values)))
+}
+
+// correlation calculates the Pearson correlation coefficient of x and y,
+// returns 0 if either has no variation
+func correlation(x []float64, y []float64) float64 {
+	n := len(x)
+	if n == 0 || n != len(y) {
+		return 0
+	}
+
+	mx, my := mean(x), mean(y)
+
+	var sxy, sxx, syy float64
+	for i := 0; i < n; i++ {
+		sxy += (x[i] - mx) * (y[i] - my)
+		sxx += (x[i] - mx) * (x[i] - mx)
+		syy += (y[i] - my) * (y[i] - my)
+	}
+
+	if sxx == 0 || syy == 0 {
+		return 0
+	}
+
+	return sxy / math.Sqrt(sxx*syy)
+}
@@ -0,0 +1,44 @@
+package analysers
+
+import (
+	"math"
+	"testing"
+
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+)
+
+func TestStatistics(t *testing.T) {
+	values := []float64{2, 4, 4, 4, 5, 5, 7, 9}
+
+	then.AssertThat(t, mean(values), is.EqualTo(5.0))
+	then.AssertThat(t, minimum(values), is.EqualTo(2.0))
+	then.AssertThat(t, stddev(values), is.EqualTo(2.0))
+
+	// no values
+	then.AssertThat(t, mean(nil), is.EqualTo(0.0))
+	then.AssertThat(t, minimum(nil), is.EqualTo(0.0))
+	then.AssertThat(t, stddev(nil), is.EqualTo(0.0))
+	then.AssertThat(t, minimum([]float64{-1, 3}), is.EqualTo(-1.0))
+}
+
+func TestCorrelation(t *testing.T) {
+	x := []float64{1, 2, 3, 4, 5}
+
+	tests := []struct {
+		y           []float64
+		correlation float64
+	}{
+		{[]float64{2, 4, 6, 8, 10}, 1},
+		{[]float64{10, 8, 6, 4, 2}, -1},
+		// no variation
+		{[]float64{3, 3, 3, 3, 3}, 0},
+		// different lengths
+		{[]float64{1, 2}, 0},
+		{[]float64{1, 3, 2, 5, 4}, 0.8},
+	}
+
+	for _, test := range tests {
+		then.AssertThat(t, math.Round(correlation(x, test.y)*1000)/1000, is.EqualTo(test.correlation))
+	}
+}
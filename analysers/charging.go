@@ -0,0 +1,158 @@
+package analysers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+)
+
+const (
+	// LowRestingVoltage resting battery voltage below which the battery is discharged
+	LowRestingVoltage = 12.2
+	// UnderchargeVoltage running voltage below which the alternator is undercharging
+	UnderchargeVoltage = 13.2
+	// OverchargeVoltage running voltage above which the regulator is overcharging
+	OverchargeVoltage = 14.8
+	// DropoutVoltage sudden drop in running voltage that is considered a dropout
+	DropoutVoltage = 1.0
+	// RippleLimit standard deviation of the idle or load voltage above which the supply is unstable
+	RippleLimit = 0.3
+	// dwellCorrelationLimit correlation between voltage and coil time above which the
+	// ECU is not compensating the dwell for the supply voltage
+	dwellCorrelationLimit = 0.3
+)
+
+// ChargingReport is the result of the charging system analysis
+type ChargingReport struct {
+	// RestingVoltage mean battery voltage with the engine off
+	RestingVoltage float32
+	// MinimumCrankVoltage lowest battery voltage while cranking
+	MinimumCrankVoltage float32
+	// IdleVoltage mean charging voltage at idle
+	IdleVoltage float32
+	// LoadVoltage mean charging voltage off idle
+	LoadVoltage float32
+	// Ripple largest standard deviation of the voltage at idle or under load, the voltages
+	// are taken per phase so the step between the idle and load voltages isn't counted
+	Ripple float32
+	// Dropouts times of sudden drops in the running voltage
+	Dropouts []string
+	// CoilTimeCorrelation correlation between the battery voltage and coil time when running,
+	// the ECU lengthens the dwell as the voltage drops so this is expected to be negative
+	CoilTimeCorrelation float64
+	// Warnings for undercharge, overcharge and unstable supply
+	Warnings []string
+}
+
+// ChargingAnalyser evaluates the battery voltage across the engine phases
+type ChargingAnalyser struct{}
+
+// NewChargingAnalyser creates a new charging system analyser
+func NewChargingAnalyser() *ChargingAnalyser {
+	return &ChargingAnalyser{}
+}
+
+// Name of the analyser
+func (analyser *ChargingAnalyser) Name() string {
+	return "charging"
+}
+
+// Analyse the battery voltage with the engine off, cranking and running
+func (analyser *ChargingAnalyser) Analyse(scenario *scenarios.Scenario) Report {
+	report := &ChargingReport{}
+	voltages := make(map[EnginePhase][]float64)
+
+	var coilVoltages, coiltime []float64
+	var previous *scenarios.MemsFCRData
+
+	// dataframes without the voltage or engine speed are ignored
+	for _, data := range withChannels(scenario.Memsdata, "BatteryVoltage", "EngineRPM") {
+		phase := GetEnginePhase(data)
+		voltage := float64(data.BatteryVoltage)
+		voltages[phase] = append(voltages[phase], voltage)
+
+		if phase.IsRunning() {
+			if data.HasChannel("CoilTime") {
+				coilVoltages = append(coilVoltages, voltage)
+				coiltime = append(coiltime, float64(data.CoilTime))
+			}
+
+			if previous != nil && previous.BatteryVoltage-data.BatteryVoltage >= DropoutVoltage {
+				report.Dropouts = append(report.Dropouts, data.Time)
+			}
+
+			previous = data
+		} else {
+			previous = nil
+		}
+	}
+
+	report.RestingVoltage = float32(mean(voltages[EngineOff]))
+	report.MinimumCrankVoltage = float32(minimum(voltages[Cranking]))
+	report.IdleVoltage = float32(mean(voltages[Idle]))
+	report.LoadVoltage = float32(mean(voltages[Load]))
+	report.Ripple = float32(math.Max(stddev(voltages[Idle]), stddev(voltages[Load])))
+	report.CoilTimeCorrelation = correlation(coilVoltages, coiltime)
+	report.Warnings = chargingWarnings(report, voltages)
+
+	return report
+}
+
+func chargingWarnings(report *ChargingReport, voltages map[EnginePhase][]float64) []string {
+	var warnings []string
+
+	if len(voltages[EngineOff]) > 0 && report.RestingVoltage < LowRestingVoltage {
+		warnings = append(warnings, fmt.Sprintf("battery discharged, resting voltage %.1fV", report.RestingVoltage))
+	}
+
+	for _, phase := range []EnginePhase{Idle, Load} {
+		if len(voltages[phase]) == 0 {
+			continue
+		}
+
+		voltage := mean(voltages[phase])
+
+		if voltage < UnderchargeVoltage {
+			warnings = append(warnings, fmt.Sprintf("undercharging at %s, %.1fV", phase, voltage))
+		}
+
+		if voltage > OverchargeVoltage {
+			warnings = append(warnings, fmt.Sprintf("overcharging at %s, %.1fV", phase, voltage))
+		}
+	}
+
+	if report.Ripple > RippleLimit {
+		warnings = append(warnings, fmt.Sprintf("unstable charging voltage, ripple %.2fV", report.Ripple))
+	}
+
+	if len(report.Dropouts) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d voltage dropouts, check the battery and earth connections", len(report.Dropouts)))
+	}
+
+	if report.CoilTimeCorrelation > dwellCorrelationLimit {
+		warnings = append(warnings, fmt.Sprintf("coil time increases with voltage (correlation %.2f), dwell is not being compensated", report.CoilTimeCorrelation))
+	}
+
+	return warnings
+}
+
+// String formats the report for display
+func (report *ChargingReport) String() string {
+	var sb strings.Builder
+
+	sb.WriteString("charging system analysis\n")
+	fmt.Fprintf(&sb, "  resting: %.1fV minimum cranking: %.1fV idle: %.1fV load: %.1fV\n", report.RestingVoltage, report.MinimumCrankVoltage, report.IdleVoltage, report.LoadVoltage)
+	fmt.Fprintf(&sb, "  ripple: %.2fV dropouts: %d coil time correlation: %.2f\n", report.Ripple, len(report.Dropouts), report.CoilTimeCorrelation)
+
+	for _, dropout := range report.Dropouts {
+		fmt.Fprintf(&sb, "    dropout at %s\n", dropout)
+	}
+
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(&sb, "  warning: %s\n", warning)
+	}
+
+	return sb.String()
+}
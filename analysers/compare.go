@@ -0,0 +1,226 @@
+package analysers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+)
+
+const (
+	// CoolantBandWidth width (°C) of the coolant temperature bands used to align the scenarios
+	CoolantBandWidth = 10
+	// minimumSamples number of dataframes required in a band before it is compared
+	minimumSamples = 3
+	// significantT Welch's t statistic above which the difference is significant
+	significantT = 3.0
+	// significantEffect difference in means, in pooled standard deviations, that is meaningful
+	significantEffect = 0.8
+)
+
+// comparisonChannel is a channel compared between the baseline and the suspect scenario,
+// Tolerance is the smallest difference that is of interest
+type comparisonChannel struct {
+	Name      string
+	Tolerance float64
+	value     func(data *scenarios.MemsFCRData) float64
+}
+
+var comparisonChannels = []comparisonChannel{
+	{"EngineRPM", 50, func(d *scenarios.MemsFCRData) float64 { return float64(d.EngineRPM) }},
+	{"ManifoldAbsolutePressure", 3, func(d *scenarios.MemsFCRData) float64 { return float64(d.ManifoldAbsolutePressure) }},
+	{"IACPosition", 5, func(d *scenarios.MemsFCRData) float64 { return float64(d.IACPosition) }},
+	{"IdleSpeedDeviation", 50, func(d *scenarios.MemsFCRData) float64 { return float64(d.IdleSpeedDeviation) }},
+	{"IgnitionAdvance", 2, func(d *scenarios.MemsFCRData) float64 { return float64(d.IgnitionAdvance) }},
+	{"CoilTime", 0.5, func(d *scenarios.MemsFCRData) float64 { return float64(d.CoilTime) }},
+	{"BatteryVoltage", 0.3, func(d *scenarios.MemsFCRData) float64 { return float64(d.BatteryVoltage) }},
+	{"ThrottlePotSensor", 0.05, func(d *scenarios.MemsFCRData) float64 { return float64(d.ThrottlePotSensor) }},
+	{"IntakeAirTemp", 5, func(d *scenarios.MemsFCRData) float64 { return float64(d.IntakeAirTemp) }},
+	{"LambdaVoltage", 50, func(d *scenarios.MemsFCRData) float64 { return float64(d.LambdaVoltage) }},
+	{"LongTermFuelTrim", 3, func(d *scenarios.MemsFCRData) float64 { return float64(d.LongTermFuelTrim) }},
+	{"ShortTermFuelTrim", 3, func(d *scenarios.MemsFCRData) float64 { return float64(d.ShortTermFuelTrim) }},
+	{"IdleBasePosition", 5, func(d *scenarios.MemsFCRData) float64 { return float64(d.IdleBasePosition) }},
+}
+
+// ChannelDifference is a channel whose values differ significantly from the baseline
+// for the same engine phase and coolant temperature band
+type ChannelDifference struct {
+	Phase          EnginePhase
+	CoolantBand    int
+	Channel        string
+	Unit           string
+	BaselineMean   float64
+	SuspectMean    float64
+	Difference     float64
+	BaselineCount  int
+	SuspectCount   int
+	TStatistic     float64
+	EffectSize     float64
+	BaselineStdDev float64
+	SuspectStdDev  float64
+}
+
+// String formats the difference for display e.g. IACPosition 20.0 steps higher at 80-89°C idle
+func (difference ChannelDifference) String() string {
+	direction := "higher"
+	if difference.Difference < 0 {
+		direction = "lower"
+	}
+
+	return fmt.Sprintf("%s %.1f %s %s at %d-%d°C %s (baseline %.1f, suspect %.1f)",
+		difference.Channel, math.Abs(difference.Difference), difference.Unit, direction,
+		difference.CoolantBand, difference.CoolantBand+CoolantBandWidth-1, difference.Phase,
+		difference.BaselineMean, difference.SuspectMean)
+}
+
+// ComparisonReport is the result of comparing a scenario against a known good baseline
+type ComparisonReport struct {
+	// Groups number of engine phase and coolant bands found in both scenarios
+	Groups int
+	// Differences ordered by significance, most significant first
+	Differences []ChannelDifference
+}
+
+// comparisonGroup is the dataframes for an engine phase and coolant temperature band
+type comparisonGroup struct {
+	phase EnginePhase
+	band  int
+}
+
+// Compare aligns the suspect scenario with the baseline by engine phase and coolant
+// temperature band and reports the channels whose values differ significantly
+func Compare(baseline *scenarios.Scenario, suspect *scenarios.Scenario) *ComparisonReport {
+	report := &ComparisonReport{}
+
+	baselineGroups := groupByPhaseAndCoolant(baseline)
+	suspectGroups := groupByPhaseAndCoolant(suspect)
+
+	var groups []comparisonGroup
+	for group := range baselineGroups {
+		if _, ok := suspectGroups[group]; ok {
+			groups = append(groups, group)
+		}
+	}
+
+	// compare in engine phase and coolant temperature order
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].phase != groups[j].phase {
+			return phaseOrder(groups[i].phase) < phaseOrder(groups[j].phase)
+		}
+
+		return groups[i].band < groups[j].band
+	})
+
+	report.Groups = len(groups)
+
+	for _, group := range groups {
+		b := baselineGroups[group]
+		s := suspectGroups[group]
+
+		if len(b) < minimumSamples || len(s) < minimumSamples {
+			continue
+		}
+
+		for _, channel := range comparisonChannels {
+			if difference, ok := compareChannel(channel, b, s); ok {
+				difference.Phase = group.phase
+				difference.CoolantBand = group.band
+				report.Differences = append(report.Differences, difference)
+			}
+		}
+	}
+
+	sort.SliceStable(report.Differences, func(i, j int) bool {
+		return report.Differences[i].EffectSize > report.Differences[j].EffectSize
+	})
+
+	return report
+}
+
+func groupByPhaseAndCoolant(scenario *scenarios.Scenario) map[comparisonGroup][]*scenarios.MemsFCRData {
+	groups := make(map[comparisonGroup][]*scenarios.MemsFCRData)
+
+	for _, data := range scenario.Memsdata {
+		group := comparisonGroup{
+			phase: GetEnginePhase(data),
+			band:  int(math.Floor(float64(data.CoolantTemp)/CoolantBandWidth)) * CoolantBandWidth,
+		}
+
+		groups[group] = append(groups[group], data)
+	}
+
+	return groups
+}
+
+// compareChannel uses Welch's t-test and the effect size to decide if the channel
+// differs significantly between the baseline and suspect dataframes
+func compareChannel(channel comparisonChannel, baseline []*scenarios.MemsFCRData, suspect []*scenarios.MemsFCRData) (ChannelDifference, bool) {
+	b := channelValues(channel, baseline)
+	s := channelValues(channel, suspect)
+
+	difference := ChannelDifference{
+		Channel:        channel.Name,
+		Unit:           scenarios.FindChannel(channel.Name).Unit,
+		BaselineMean:   mean(b),
+		SuspectMean:    mean(s),
+		BaselineCount:  len(b),
+		SuspectCount:   len(s),
+		BaselineStdDev: stddev(b),
+		SuspectStdDev:  stddev(s),
+	}
+
+	difference.Difference = difference.SuspectMean - difference.BaselineMean
+
+	if math.Abs(difference.Difference) < channel.Tolerance {
+		return difference, false
+	}
+
+	bv := difference.BaselineStdDev * difference.BaselineStdDev
+	sv := difference.SuspectStdDev * difference.SuspectStdDev
+	pooled := math.Sqrt((bv + sv) / 2)
+	stderr := math.Sqrt(bv/float64(len(b)) + sv/float64(len(s)))
+
+	if pooled == 0 || stderr == 0 {
+		// both channels are constant and differ by more than the tolerance
+		difference.TStatistic = math.Inf(1)
+		difference.EffectSize = math.Inf(1)
+		return difference, true
+	}
+
+	difference.TStatistic = math.Abs(difference.Difference) / stderr
+	difference.EffectSize = math.Abs(difference.Difference) / pooled
+
+	return difference, difference.TStatistic > significantT && difference.EffectSize > significantEffect
+}
+
+// channelValues returns the values of the channel, dataframes missing the channel are ignored
+func channelValues(channel comparisonChannel, memsdata []*scenarios.MemsFCRData) []float64 {
+	var values []float64
+
+	for _, data := range memsdata {
+		if data.HasChannel(channel.Name) {
+			values = append(values, channel.value(data))
+		}
+	}
+
+	return values
+}
+
+// String formats the report for display
+func (report *ComparisonReport) String() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "baseline comparison (%d engine phase and coolant bands in common)\n", report.Groups)
+
+	if len(report.Differences) == 0 {
+		sb.WriteString("  no significant differences from the baseline\n")
+	}
+
+	for _, difference := range report.Differences {
+		fmt.Fprintf(&sb, "  %s\n", difference)
+	}
+
+	return sb.String()
+}
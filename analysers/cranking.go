@@ -0,0 +1,232 @@
+package analysers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+)
+
+const (
+	// WeakBatteryVoltage minimum voltage during cranking below which the battery is considered weak
+	WeakBatteryVoltage = 9.6
+	// SlowStartTime time from the first crank signal to stable idle above which the start is considered slow
+	SlowStartTime = 3 * time.Second
+	// ColdSlowStartTime slow start time when the engine is cold
+	ColdSlowStartTime = 5 * time.Second
+	// SlowCrankingRPM cranking speeds below this indicate a weak battery or starter motor
+	SlowCrankingRPM = 150
+	// coldStartTemperature coolant temperature at or below which the engine is considered cold
+	coldStartTemperature = 10
+	// stableIdleFrames number of consecutive running dataframes required for a stable idle
+	stableIdleFrames = 3
+	// stableIdleVariation maximum variation in rpm for the idle to be considered stable
+	stableIdleVariation = 200
+)
+
+// CrankingEvent is a single attempt to start the engine
+type CrankingEvent struct {
+	// Time the cranking started
+	Time string
+	// Started true if the engine reached a stable idle
+	Started bool
+	// TimeToStart duration from the first crank signal to stable idle
+	TimeToStart time.Duration
+	// RestingVoltage battery voltage before cranking, 0 if the log starts while cranking
+	RestingVoltage float32
+	// MinimumVoltage lowest battery voltage while cranking
+	MinimumVoltage float32
+	// VoltageSag drop in battery voltage while cranking, 0 if the resting voltage isn't known
+	VoltageSag float32
+	// CrankingRPM mean engine speed on the starter
+	CrankingRPM int
+	// CoilTime mean coil time (ms) while cranking
+	CoilTime float32
+	// CoolantTemp coolant temperature at the start
+	CoolantTemp int
+	// Warnings for weak battery, slow cranking and slow starts
+	Warnings []string
+}
+
+// CrankingReport is the result of the cranking analysis
+type CrankingReport struct {
+	Events []*CrankingEvent
+}
+
+// CrankingAnalyser finds the cranking events in the scenario and evaluates the starts
+type CrankingAnalyser struct{}
+
+// NewCrankingAnalyser creates a new cranking analyser
+func NewCrankingAnalyser() *CrankingAnalyser {
+	return &CrankingAnalyser{}
+}
+
+// Name of the analyser
+func (analyser *CrankingAnalyser) Name() string {
+	return "cranking"
+}
+
+// Analyse the cranking events in the scenario, an event starts when the engine turns
+// after being stopped and ends when the engine reaches a stable idle or stops again
+func (analyser *CrankingAnalyser) Analyse(scenario *scenarios.Scenario) Report {
+	report := &CrankingReport{}
+	elapsed := scenario.Elapsed()
+
+	for i := 0; i < len(scenario.Memsdata); i++ {
+		data := scenario.Memsdata[i]
+
+		// a log that starts while the engine is cranking starts with an event
+		if i == 0 && GetEnginePhase(data) != Cranking {
+			continue
+		}
+
+		if i > 0 && GetEnginePhase(scenario.Memsdata[i-1]) != EngineOff {
+			continue
+		}
+
+		if GetEnginePhase(data) == EngineOff && !data.CrankshaftPositionSensor {
+			continue
+		}
+
+		event, end := analyser.evaluateEvent(scenario, elapsed, i)
+		report.Events = append(report.Events, event)
+		i = end
+	}
+
+	return report
+}
+
+// evaluateEvent measures the cranking event starting at the dataframe index start,
+// returns the event and the index of the last dataframe in the event
+func (analyser *CrankingAnalyser) evaluateEvent(scenario *scenarios.Scenario, elapsed []time.Duration, start int) (*CrankingEvent, int) {
+	event := &CrankingEvent{
+		Time:           scenario.Memsdata[start].Time,
+		MinimumVoltage: scenario.Memsdata[start].BatteryVoltage,
+		CoolantTemp:    scenario.Memsdata[start].CoolantTemp,
+	}
+
+	if start > 0 {
+		event.RestingVoltage = scenario.Memsdata[start-1].BatteryVoltage
+		event.MinimumVoltage = event.RestingVoltage
+	}
+
+	var rpm, coiltime []float64
+	end := start
+
+	for ; end < len(scenario.Memsdata); end++ {
+		data := scenario.Memsdata[end]
+		phase := GetEnginePhase(data)
+
+		if phase == EngineOff && end > start {
+			// engine stopped, failed start
+			end--
+			break
+		}
+
+		if phase == Cranking {
+			rpm = append(rpm, float64(data.EngineRPM))
+
+			if data.HasChannel("CoilTime") {
+				coiltime = append(coiltime, float64(data.CoilTime))
+			}
+
+			if data.HasChannel("BatteryVoltage") {
+				event.MinimumVoltage = float32(math.Min(float64(event.MinimumVoltage), float64(data.BatteryVoltage)))
+			}
+		}
+
+		if isStableIdle(scenario.Memsdata, end) {
+			event.Started = true
+			event.TimeToStart = elapsed[end] - elapsed[start]
+			break
+		}
+	}
+
+	if end >= len(scenario.Memsdata) {
+		end = len(scenario.Memsdata) - 1
+	}
+
+	if start > 0 {
+		event.VoltageSag = event.RestingVoltage - event.MinimumVoltage
+	}
+	event.CrankingRPM = int(math.Round(mean(rpm)))
+	event.CoilTime = float32(mean(coiltime))
+	event.Warnings = crankingWarnings(event, len(rpm) > 0)
+
+	return event, end
+}
+
+// isStableIdle returns true if the engine is running at a steady speed
+// from the dataframe index
+func isStableIdle(memsdata []*scenarios.MemsFCRData, index int) bool {
+	if index+stableIdleFrames > len(memsdata) {
+		return false
+	}
+
+	min, max := math.MaxInt32, 0
+
+	for _, data := range memsdata[index : index+stableIdleFrames] {
+		if !GetEnginePhase(data).IsRunning() {
+			return false
+		}
+
+		if data.EngineRPM < min {
+			min = data.EngineRPM
+		}
+
+		if data.EngineRPM > max {
+			max = data.EngineRPM
+		}
+	}
+
+	return max-min <= stableIdleVariation
+}
+
+func crankingWarnings(event *CrankingEvent, hasCrankingData bool) []string {
+	var warnings []string
+
+	if hasCrankingData && event.MinimumVoltage < WeakBatteryVoltage {
+		warnings = append(warnings, fmt.Sprintf("weak battery, voltage dropped to %.1fV while cranking", event.MinimumVoltage))
+	}
+
+	if hasCrankingData && event.CrankingRPM < SlowCrankingRPM {
+		warnings = append(warnings, fmt.Sprintf("slow cranking speed %d rpm", event.CrankingRPM))
+	}
+
+	if !event.Started {
+		warnings = append(warnings, "engine did not reach a stable idle")
+		return warnings
+	}
+
+	limit := SlowStartTime
+	if event.CoolantTemp <= coldStartTemperature {
+		limit = ColdSlowStartTime
+	}
+
+	if event.TimeToStart > limit {
+		warnings = append(warnings, fmt.Sprintf("slow start, %s to stable idle", event.TimeToStart))
+	}
+
+	return warnings
+}
+
+// String formats the report for display
+func (report *CrankingReport) String() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "cranking analysis (%d events)\n", len(report.Events))
+
+	for _, event := range report.Events {
+		fmt.Fprintf(&sb, "  %s started: %t time to start: %s coolant: %d°C\n", event.Time, event.Started, event.TimeToStart, event.CoolantTemp)
+		fmt.Fprintf(&sb, "    battery resting: %.1fV minimum: %.1fV sag: %.1fV\n", event.RestingVoltage, event.MinimumVoltage, event.VoltageSag)
+		fmt.Fprintf(&sb, "    cranking rpm: %d coil time: %.2fms\n", event.CrankingRPM, event.CoilTime)
+
+		for _, warning := range event.Warnings {
+			fmt.Fprintf(&sb, "    warning: %s\n", warning)
+		}
+	}
+
+	return sb.String()
+}
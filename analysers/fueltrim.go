@@ -0,0 +1,290 @@
+package analysers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+)
+
+const (
+	// FuelTrimLimit is the combined trim (%) beyond which the mixture is considered lean or rich
+	FuelTrimLimit = 10
+	// shortTermFuelTrimNeutral is the short term fuel trim value that applies no correction
+	shortTermFuelTrimNeutral = 100
+	// midRPMLimit upper bound of the mid RPM bin
+	midRPMLimit = 3000
+	// partLoadLimit upper bound (kPa) of the part load bin
+	partLoadLimit = 70
+	// warmCoolantTemp coolant temperature (°C) at which the engine is warm, the ECU normally
+	// runs closed loop at warm idle
+	warmCoolantTemp = 80
+)
+
+// fuelTrimChannels are the channels the analysis needs, dataframes missing any are ignored
+var fuelTrimChannels = []string{"EngineRPM", "ManifoldAbsolutePressure", "ClosedLoop", "LongTermFuelTrim", "ShortTermFuelTrim"}
+
+// rpm and load bin names
+var (
+	rpmBins  = []string{"idle", "mid", "high"}
+	loadBins = []string{"low", "part", "full"}
+)
+
+// FuelTrimBin is the fuel trim summary for an RPM and load (MAP) range
+type FuelTrimBin struct {
+	RPM       string
+	Load      string
+	Count     int
+	LongTerm  float64
+	ShortTerm float64
+	Combined  float64
+}
+
+// FuelTrimReport is the result of the fuel trim analysis
+type FuelTrimReport struct {
+	// ClosedLoopCount number of closed loop dataframes used for the analysis
+	ClosedLoopCount int
+	// WarmIdleCount number of warm idle dataframes used when there are no closed loop dataframes
+	WarmIdleCount int
+	// Bins fuel trims by rpm and load
+	Bins []FuelTrimBin
+	// IdleTrim mean combined trim at idle and low load
+	IdleTrim float64
+	// LoadTrim mean combined trim at part and full load
+	LoadTrim float64
+	// Diagnosis classification of the mixture, e.g. lean at idle only
+	Diagnosis string
+	// Causes ranked list of likely causes, most likely first
+	Causes []Cause
+}
+
+// FuelTrimAnalyser tracks the long and short term fuel trims against load and rpm
+type FuelTrimAnalyser struct {
+	bins map[string]*fuelTrimSamples
+}
+
+type fuelTrimSamples struct {
+	longterm  []float64
+	shortterm []float64
+	combined  []float64
+}
+
+// NewFuelTrimAnalyser creates a new fuel trim analyser
+func NewFuelTrimAnalyser() *FuelTrimAnalyser {
+	return &FuelTrimAnalyser{}
+}
+
+// Name of the analyser
+func (analyser *FuelTrimAnalyser) Name() string {
+	return "fueltrim"
+}
+
+// Analyse the fuel trims in the scenario, only closed loop data is used as the
+// ECU doesn't apply trims when running open loop. Logs without any closed loop
+// dataframes fall back to the warm idle dataframes
+func (analyser *FuelTrimAnalyser) Analyse(scenario *scenarios.Scenario) Report {
+	report := &FuelTrimReport{}
+	analyser.bins = make(map[string]*fuelTrimSamples)
+
+	var idleTrims, loadTrims []float64
+
+	frames := closedLoopFrames(scenario.Memsdata)
+	report.ClosedLoopCount = len(frames)
+
+	if len(frames) == 0 {
+		frames = warmIdleFrames(scenario.Memsdata)
+		report.WarmIdleCount = len(frames)
+	}
+
+	for _, data := range frames {
+		shortterm := float64(data.ShortTermFuelTrim - shortTermFuelTrimNeutral)
+		longterm := float64(data.LongTermFuelTrim)
+		combined := longterm + shortterm
+
+		rpm := rpmBin(data.EngineRPM)
+		load := loadBin(data.ManifoldAbsolutePressure)
+
+		samples := analyser.getBin(rpm, load)
+		samples.longterm = append(samples.longterm, longterm)
+		samples.shortterm = append(samples.shortterm, shortterm)
+		samples.combined = append(samples.combined, combined)
+
+		// overrun also gives a low load so idle is low rpm and low load only
+		if rpm == rpmBins[0] && load == loadBins[0] {
+			idleTrims = append(idleTrims, combined)
+		}
+
+		if load != loadBins[0] {
+			loadTrims = append(loadTrims, combined)
+		}
+	}
+
+	for _, rpm := range rpmBins {
+		for _, load := range loadBins {
+			if samples, ok := analyser.bins[rpm+load]; ok {
+				report.Bins = append(report.Bins, FuelTrimBin{
+					RPM:       rpm,
+					Load:      load,
+					Count:     len(samples.combined),
+					LongTerm:  mean(samples.longterm),
+					ShortTerm: mean(samples.shortterm),
+					Combined:  mean(samples.combined),
+				})
+			}
+		}
+	}
+
+	report.IdleTrim = mean(idleTrims)
+	report.LoadTrim = mean(loadTrims)
+	report.Diagnosis, report.Causes = classifyFuelTrims(report.IdleTrim, len(idleTrims) > 0, report.LoadTrim, len(loadTrims) > 0)
+
+	return report
+}
+
+// closedLoopFrames returns the dataframes with the engine running in closed loop
+func closedLoopFrames(memsdata []*scenarios.MemsFCRData) []*scenarios.MemsFCRData {
+	var frames []*scenarios.MemsFCRData
+
+	for _, data := range withChannels(memsdata, fuelTrimChannels...) {
+		if data.ClosedLoop && data.EngineRPM > 0 {
+			frames = append(frames, data)
+		}
+	}
+
+	return frames
+}
+
+// warmIdleFrames returns the dataframes at idle with the engine warm
+func warmIdleFrames(memsdata []*scenarios.MemsFCRData) []*scenarios.MemsFCRData {
+	var frames []*scenarios.MemsFCRData
+
+	for _, data := range withChannels(memsdata, append(fuelTrimChannels, "CoolantTemp")...) {
+		if GetEnginePhase(data) == Idle && data.CoolantTemp >= warmCoolantTemp {
+			frames = append(frames, data)
+		}
+	}
+
+	return frames
+}
+
+func (analyser *FuelTrimAnalyser) getBin(rpm string, load string) *fuelTrimSamples {
+	samples, ok := analyser.bins[rpm+load]
+	if !ok {
+		samples = &fuelTrimSamples{}
+		analyser.bins[rpm+load] = samples
+	}
+
+	return samples
+}
+
+// classifyFuelTrims uses the idle and load trims to determine where the mixture is lean or
+// rich and ranks the likely causes, positive trims mean the ECU is adding fuel (lean)
+func classifyFuelTrims(idle float64, hasIdle bool, load float64, hasLoad bool) (string, []Cause) {
+	if !hasIdle && !hasLoad {
+		return "no closed loop or warm idle data, fuel trims cannot be evaluated", nil
+	}
+
+	// how far beyond the limits the trims are
+	leanIdle := excess(idle, hasIdle)
+	leanLoad := excess(load, hasLoad)
+	richIdle := excess(-idle, hasIdle)
+	richLoad := excess(-load, hasLoad)
+
+	candidates := []Cause{
+		{Description: "vacuum or air leak after the MAP sensor (lean at idle only)", Score: leanIdle - leanLoad},
+		{Description: "fuel delivery, pump, filter or pressure regulator (lean at load)", Score: leanLoad - leanIdle},
+		{Description: "low fuel pressure, restricted injectors or MAP sensor reading low (lean everywhere)", Score: math.Min(leanIdle, leanLoad)},
+		{Description: "leaking injectors, high fuel pressure or MAP sensor reading high (rich everywhere)", Score: math.Min(richIdle, richLoad)},
+		{Description: "purge valve stuck open or dripping injector (rich at idle only)", Score: richIdle - richLoad},
+		{Description: "fuel pressure regulator or MAP sensor at high load (rich at load)", Score: richLoad - richIdle},
+	}
+
+	var causes []Cause
+	for _, cause := range candidates {
+		if cause.Score > 0 {
+			causes = append(causes, cause)
+		}
+	}
+
+	sort.SliceStable(causes, func(i, j int) bool {
+		return causes[i].Score > causes[j].Score
+	})
+
+	var diagnosis string
+
+	switch {
+	case leanIdle > 0 && leanLoad > 0:
+		diagnosis = "lean everywhere"
+	case richIdle > 0 && richLoad > 0:
+		diagnosis = "rich everywhere"
+	case leanIdle > 0:
+		diagnosis = "lean at idle only"
+	case leanLoad > 0:
+		diagnosis = "lean at load"
+	case richIdle > 0:
+		diagnosis = "rich at idle only"
+	case richLoad > 0:
+		diagnosis = "rich at load"
+	default:
+		diagnosis = "fuel trims within limits"
+	}
+
+	return diagnosis, causes
+}
+
+// excess returns how far the trim exceeds the limit, 0 if it doesn't or there is no data
+func excess(trim float64, hasData bool) float64 {
+	if !hasData || trim <= FuelTrimLimit {
+		return 0
+	}
+
+	return trim - FuelTrimLimit
+}
+
+func rpmBin(rpm int) string {
+	switch {
+	case rpm < idleRPMLimit:
+		return rpmBins[0]
+	case rpm < midRPMLimit:
+		return rpmBins[1]
+	default:
+		return rpmBins[2]
+	}
+}
+
+func loadBin(kpa float32) string {
+	switch {
+	case kpa < lowLoadLimit:
+		return loadBins[0]
+	case kpa < partLoadLimit:
+		return loadBins[1]
+	default:
+		return loadBins[2]
+	}
+}
+
+// String formats the report for display
+func (report *FuelTrimReport) String() string {
+	var sb strings.Builder
+
+	if report.ClosedLoopCount == 0 && report.WarmIdleCount > 0 {
+		fmt.Fprintf(&sb, "fuel trim analysis (no closed loop dataframes, %d warm idle dataframes)\n", report.WarmIdleCount)
+	} else {
+		fmt.Fprintf(&sb, "fuel trim analysis (%d closed loop dataframes)\n", report.ClosedLoopCount)
+	}
+	sb.WriteString("  rpm   load  count  long  short  combined\n")
+
+	for _, bin := range report.Bins {
+		fmt.Fprintf(&sb, "  %-5s %-5s %5d %5.1f %6.1f %9.1f\n", bin.RPM, bin.Load, bin.Count, bin.LongTerm, bin.ShortTerm, bin.Combined)
+	}
+
+	fmt.Fprintf(&sb, "diagnosis: %s\n", report.Diagnosis)
+
+	for i, cause := range report.Causes {
+		fmt.Fprintf(&sb, "  %d. %s\n", i+1, cause)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,62 @@
+package analysers
+
+import (
+	"github.com/andrewdjackson/memscene/scenarios"
+)
+
+// EnginePhase is the operating state of the engine when the dataframe was recorded
+type EnginePhase string
+
+const (
+	// EngineOff ignition on, engine not turning
+	EngineOff = EnginePhase("off")
+	// Cranking engine turning on the starter motor
+	Cranking = EnginePhase("cranking")
+	// Idle engine running at idle
+	Idle = EnginePhase("idle")
+	// Load engine running off idle
+	Load = EnginePhase("load")
+)
+
+const (
+	// crankingRPMLimit engine speeds below this are considered to be on the starter
+	crankingRPMLimit = 400
+	// idleRPMLimit engine speeds below this are considered idle, the upper bound of the idle RPM bin
+	idleRPMLimit = 1200
+	// lowLoadLimit manifold pressures (kPa) below this are considered low load, the upper bound of
+	// the low load (vacuum) bin
+	lowLoadLimit = 45
+)
+
+// EnginePhases lists the phases in the order they normally occur
+var EnginePhases = []EnginePhase{EngineOff, Cranking, Idle, Load}
+
+// GetEnginePhase determines the engine phase from the dataframe
+func GetEnginePhase(data *scenarios.MemsFCRData) EnginePhase {
+	switch {
+	case data.EngineRPM == 0:
+		return EngineOff
+	case data.EngineRPM < crankingRPMLimit:
+		return Cranking
+	case data.EngineRPM < idleRPMLimit && (data.IdleSwitch || data.ManifoldAbsolutePressure < lowLoadLimit):
+		return Idle
+	default:
+		return Load
+	}
+}
+
+// IsRunning returns true if the engine is running under its own power
+func (phase EnginePhase) IsRunning() bool {
+	return phase == Idle || phase == Load
+}
+
+// phaseOrder returns the position of the phase in EnginePhases
+func phaseOrder(phase EnginePhase) int {
+	for i, p := range EnginePhases {
+		if p == phase {
+			return i
+		}
+	}
+
+	return len(EnginePhases)
+}
@@ -0,0 +1,194 @@
+package analysers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+)
+
+const (
+	// ClosedThrottleMinVoltage lowest acceptable throttle pot voltage with the throttle closed
+	ClosedThrottleMinVoltage = 0.1
+	// ClosedThrottleMaxVoltage highest acceptable throttle pot voltage with the throttle closed
+	ClosedThrottleMaxVoltage = 1.0
+	// WideOpenThrottleMinVoltage lowest acceptable throttle pot voltage with the throttle fully open
+	WideOpenThrottleMinVoltage = 4.0
+	// closedThrottleBand voltage above the closed throttle voltage still considered closed
+	closedThrottleBand = 0.1
+	// openThrottleBand voltage above the closed throttle voltage at which the idle switch must be off
+	openThrottleBand = 0.3
+	// glitchVoltage change in voltage between dataframes that indicates a glitch when it reverses
+	glitchVoltage = 0.5
+	// deadSpotAngle change in throttle angle with no change in voltage that indicates a dead spot
+	deadSpotAngle = 5
+	// deadSpotVoltage change in voltage considered to be no change
+	deadSpotVoltage = 0.02
+)
+
+// ThrottleReport is the result of the throttle potentiometer check
+type ThrottleReport struct {
+	// ClosedVoltage throttle pot voltage with the throttle closed
+	ClosedVoltage float32
+	// MaximumVoltage highest throttle pot voltage seen during the sweep
+	MaximumVoltage float32
+	// IdleSwitchDisagreements number of dataframes where the idle switch and the pot disagree
+	IdleSwitchDisagreements int
+	// Glitches times of sudden jumps in the pot voltage
+	Glitches []string
+	// DeadSpots times where the pot voltage dropped out or didn't follow the throttle
+	DeadSpots []string
+	// Failures reasons the check failed
+	Failures []string
+	// Pass true if the throttle pot passed all the checks
+	Pass bool
+}
+
+// ThrottleAnalyser checks the throttle potentiometer calibration, this should be run on a
+// log where the throttle is slowly swept from closed to fully open and back with the engine off.
+// It isn't one of All the analysers, it's only run when requested by name
+type ThrottleAnalyser struct{}
+
+// NewThrottleAnalyser creates a new throttle pot analyser
+func NewThrottleAnalyser() *ThrottleAnalyser {
+	return &ThrottleAnalyser{}
+}
+
+// Name of the analyser
+func (analyser *ThrottleAnalyser) Name() string {
+	return "throttle"
+}
+
+// Analyse the throttle pot sweep
+func (analyser *ThrottleAnalyser) Analyse(scenario *scenarios.Scenario) Report {
+	report := &ThrottleReport{}
+	memsdata := withChannels(scenario.Memsdata, "ThrottlePotSensor", "ThrottleAngle", "IdleSwitch")
+
+	if len(memsdata) == 0 {
+		report.Failures = append(report.Failures, "no data")
+		return report
+	}
+
+	report.ClosedVoltage = float32(math.MaxFloat32)
+
+	for _, data := range memsdata {
+		if data.ThrottlePotSensor > report.MaximumVoltage {
+			report.MaximumVoltage = data.ThrottlePotSensor
+		}
+
+		// ignore pot dropouts when looking for the closed throttle voltage
+		if data.ThrottlePotSensor >= ClosedThrottleMinVoltage && data.ThrottlePotSensor < report.ClosedVoltage {
+			report.ClosedVoltage = data.ThrottlePotSensor
+		}
+	}
+
+	if report.ClosedVoltage == float32(math.MaxFloat32) {
+		report.ClosedVoltage = 0
+	}
+
+	for i, data := range memsdata {
+		// the idle switch must be on at closed throttle and off once the throttle is open,
+		// a pot dropout is a dead spot rather than a closed throttle
+		dropout := data.ThrottlePotSensor < ClosedThrottleMinVoltage
+
+		if !dropout && data.ThrottlePotSensor <= report.ClosedVoltage+closedThrottleBand && !data.IdleSwitch {
+			report.IdleSwitchDisagreements++
+		}
+
+		if data.ThrottlePotSensor >= report.ClosedVoltage+openThrottleBand && data.IdleSwitch {
+			report.IdleSwitchDisagreements++
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		previous := memsdata[i-1]
+
+		// voltage dropping to zero mid sweep, the wiper has lost contact with the track
+		if data.ThrottlePotSensor < ClosedThrottleMinVoltage && previous.ThrottlePotSensor >= ClosedThrottleMinVoltage {
+			report.DeadSpots = append(report.DeadSpots, data.Time)
+			continue
+		}
+
+		// throttle has moved but the voltage hasn't followed
+		angle := math.Abs(float64(data.ThrottleAngle - previous.ThrottleAngle))
+		voltage := math.Abs(float64(data.ThrottlePotSensor - previous.ThrottlePotSensor))
+
+		if angle >= deadSpotAngle && voltage < deadSpotVoltage {
+			report.DeadSpots = append(report.DeadSpots, data.Time)
+			continue
+		}
+
+		if i < len(memsdata)-1 && isGlitch(previous.ThrottlePotSensor, data.ThrottlePotSensor, memsdata[i+1].ThrottlePotSensor) {
+			report.Glitches = append(report.Glitches, data.Time)
+		}
+	}
+
+	report.Failures = throttleFailures(report)
+	report.Pass = len(report.Failures) == 0
+
+	return report
+}
+
+// isGlitch returns true if the voltage jumps and immediately returns
+func isGlitch(previous float32, current float32, next float32) bool {
+	up := current-previous > glitchVoltage && current-next > glitchVoltage
+	down := previous-current > glitchVoltage && next-current > glitchVoltage
+
+	return (up || down) && math.Abs(float64(next-previous)) < glitchVoltage/2
+}
+
+func throttleFailures(report *ThrottleReport) []string {
+	var failures []string
+
+	if report.ClosedVoltage < ClosedThrottleMinVoltage || report.ClosedVoltage > ClosedThrottleMaxVoltage {
+		failures = append(failures, fmt.Sprintf("closed throttle voltage %.2fV outside %.1fV - %.1fV", report.ClosedVoltage, ClosedThrottleMinVoltage, ClosedThrottleMaxVoltage))
+	}
+
+	if report.MaximumVoltage < WideOpenThrottleMinVoltage {
+		failures = append(failures, fmt.Sprintf("maximum voltage %.2fV below %.1fV, throttle not fully opened or pot out of range", report.MaximumVoltage, WideOpenThrottleMinVoltage))
+	}
+
+	if report.IdleSwitchDisagreements > 0 {
+		failures = append(failures, fmt.Sprintf("idle switch disagrees with the pot in %d dataframes", report.IdleSwitchDisagreements))
+	}
+
+	if len(report.Glitches) > 0 {
+		failures = append(failures, fmt.Sprintf("%d voltage jumps during the sweep", len(report.Glitches)))
+	}
+
+	if len(report.DeadSpots) > 0 {
+		failures = append(failures, fmt.Sprintf("%d dead spots during the sweep", len(report.DeadSpots)))
+	}
+
+	return failures
+}
+
+// String formats the report for display
+func (report *ThrottleReport) String() string {
+	var sb strings.Builder
+
+	result := "FAIL"
+	if report.Pass {
+		result = "PASS"
+	}
+
+	fmt.Fprintf(&sb, "throttle pot check: %s\n", result)
+	fmt.Fprintf(&sb, "  closed: %.2fV maximum: %.2fV idle switch disagreements: %d\n", report.ClosedVoltage, report.MaximumVoltage, report.IdleSwitchDisagreements)
+
+	for _, glitch := range report.Glitches {
+		fmt.Fprintf(&sb, "    jump at %s\n", glitch)
+	}
+
+	for _, deadspot := range report.DeadSpots {
+		fmt.Fprintf(&sb, "    dead spot at %s\n", deadspot)
+	}
+
+	for _, failure := range report.Failures {
+		fmt.Fprintf(&sb, "  fail: %s\n", failure)
+	}
+
+	return sb.String()
+}
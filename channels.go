@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+)
+
+// channels prints the channels with their units and metadata
+//
+// memscene channels [-json] [channel]
+func channels(args []string) {
+	var asJSON bool
+
+	flags := flag.NewFlagSet("channels", flag.ExitOnError)
+	flags.BoolVar(&asJSON, "json", false, "print the channels as JSON")
+	flags.Parse(args)
+
+	if flags.NArg() > 1 {
+		fmt.Println("Usage of ./memscene channels [-json] [channel]")
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+
+	selected := scenarios.Channels
+
+	if flags.NArg() == 1 {
+		channel := scenarios.FindChannel(flags.Arg(0))
+		if channel == nil {
+			utils.LogE.Fatalf("unknown channel '%s'", flags.Arg(0))
+		}
+		selected = []*scenarios.Channel{channel}
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(selected)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "CHANNEL\tCOLUMN\tUNIT\tSOURCE\tSCALING\tRANGE\tDESCRIPTION\n")
+
+	for _, channel := range selected {
+		valid := ""
+		if channel.Min != channel.Max {
+			valid = fmt.Sprintf("%g to %g", channel.Min, channel.Max)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", channel.Name, channel.Column, channel.Unit, channel.Source, channel.Scaling(), valid, channel.Description)
+	}
+
+	w.Flush()
+}
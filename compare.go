@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/andrewdjackson/memscene/analysers"
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+)
+
+// compare a suspect log against a known good baseline log
+//
+// memscene compare -baseline logfiles/nofaults-warm.csv suspect.csv
+func compare(args []string) {
+	var baseline string
+
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	flags.StringVar(&baseline, "baseline", "", "known good reference log")
+	flags.Parse(args)
+
+	if baseline == "" || flags.NArg() != 1 {
+		fmt.Println("Usage of ./memscene compare -baseline <file> <suspect file>")
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+
+	reference := loadComparisonLog(baseline)
+	suspect := loadComparisonLog(flags.Arg(0))
+
+	fmt.Print(analysers.Compare(reference, suspect))
+}
+
+// loadComparisonLog converts the log, memsdiag logs don't have enough data to compare
+// so a warning is logged and memscene exits without an error
+func loadComparisonLog(file string) *scenarios.Scenario {
+	if utils.GetFileType(file) == utils.MemsDiagFile {
+		utils.LogW.Printf("unable to process memsdiag files, not enough data")
+		os.Exit(0)
+	}
+
+	scenario, err := scenarios.ConvertFile(file)
+	if err != nil {
+		utils.LogE.Fatalf("unable to load %s", err)
+	}
+
+	return scenario
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+)
+
+// convert every recognised file in the directory
+//
+// memscene convert -dir logs/ -out converted/ [-workers n]
+func convert(args []string) {
+	var dir string
+	var out string
+	var workers int
+
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	flags.StringVar(&dir, "dir", "", "directory of files to convert")
+	flags.StringVar(&out, "out", "converted", "destination directory")
+	flags.IntVar(&workers, "workers", runtime.NumCPU(), "number of files to convert in parallel")
+	flags.Parse(args)
+
+	if dir == "" || flags.NArg() != 0 {
+		fmt.Println("Usage of ./memscene convert -dir <directory> [-out <directory>]")
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+
+	report, err := scenarios.ConvertDirectory(dir, out, workers)
+	if err != nil {
+		utils.LogE.Fatalf("unable to convert %s %s", dir, err)
+	}
+
+	fmt.Print(report)
+
+	if report.Count(scenarios.Failed) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+)
+
+// diff two converted scenarios, MemsFCR CSV files are compared with the values as written
+//
+// memscene diff [-time] a.csv b.csv
+func diff(args []string) {
+	var byTime bool
+
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.BoolVar(&byTime, "time", false, "match the dataframes by time instead of index")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		fmt.Println("Usage of ./memscene diff [-time] <file> <file>")
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+
+	a, err := scenarios.LoadFile(flags.Arg(0))
+	if err != nil {
+		utils.LogE.Fatalf("unable to load %s", err)
+	}
+
+	b, err := scenarios.LoadFile(flags.Arg(1))
+	if err != nil {
+		utils.LogE.Fatalf("unable to load %s", err)
+	}
+
+	match := scenarios.MatchByIndex
+	if byTime {
+		match = scenarios.MatchByTime
+	}
+
+	fmt.Print(a.Diff(b, match))
+}
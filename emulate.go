@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/andrewdjackson/memscene/emulator"
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+)
+
+// emulate a MEMS 1.6 ECU replaying the scenario over a pseudo terminal
+//
+// memscene emulate [-ecuid 99000203] <scenario>
+func emulate(args []string) {
+	var ecuid string
+
+	flags := flag.NewFlagSet("emulate", flag.ExitOnError)
+	flags.StringVar(&ecuid, "ecuid", "99000203", "ECU id returned to the 0xD0 command")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Println("Usage of ./memscene emulate <scenario>")
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+
+	scenario, err := scenarios.ConvertFile(flags.Arg(0))
+	if err != nil || scenario.Count == 0 {
+		utils.LogE.Fatalf("unable to load scenario %v", err)
+	}
+
+	port, tty, err := emulator.OpenPseudoTerminal()
+	if err != nil {
+		utils.LogE.Fatalf("unable to open pseudo terminal %s", err)
+	}
+	defer port.Close()
+	defer tty.Close()
+
+	name := tty.Name()
+
+	utils.LogI.Printf("emulating MEMS 1.6 ECU on %s", name)
+	fmt.Println(name)
+
+	e := emulator.NewEmulator(scenario)
+
+	if e.ECUID, err = hex.DecodeString(ecuid); err != nil {
+		utils.LogE.Fatalf("invalid ecu id %s", err)
+	}
+
+	if err = e.Serve(port); err != nil {
+		utils.LogE.Fatalf("emulator stopped %s", err)
+	}
+}
@@ -0,0 +1,81 @@
+package emulator
+
+// MEMS 1.6 serial protocol commands, each command is a single byte and the ECU
+// responds by echoing the command followed by any data
+const (
+	// InitCommandA first byte of the initialisation sequence
+	InitCommandA = byte(0xCA)
+	// InitCommandB second byte of the initialisation sequence
+	InitCommandB = byte(0x75)
+	// HeartbeatCommand keeps the connection alive
+	HeartbeatCommand = byte(0xF4)
+	// ECUIDCommand requests the ECU id
+	ECUIDCommand = byte(0xD0)
+	// Dataframe80Command requests the 0x80 dataframe
+	Dataframe80Command = byte(0x80)
+	// Dataframe7dCommand requests the 0x7d dataframe
+	Dataframe7dCommand = byte(0x7D)
+	// ClearFaultsCommand clears the fault codes
+	ClearFaultsCommand = byte(0xCC)
+	// FuelPumpOn actuator command
+	FuelPumpOn = byte(0x11)
+	// FuelPumpOff actuator command
+	FuelPumpOff = byte(0x01)
+	// PTCRelayOn actuator command
+	PTCRelayOn = byte(0x12)
+	// PTCRelayOff actuator command
+	PTCRelayOff = byte(0x02)
+	// ACRelayOn actuator command
+	ACRelayOn = byte(0x13)
+	// ACRelayOff actuator command
+	ACRelayOff = byte(0x03)
+	// PurgeValveOn actuator command
+	PurgeValveOn = byte(0x18)
+	// PurgeValveOff actuator command
+	PurgeValveOff = byte(0x08)
+	// O2HeaterOn actuator command
+	O2HeaterOn = byte(0x19)
+	// O2HeaterOff actuator command
+	O2HeaterOff = byte(0x09)
+	// BoostValveOn actuator command
+	BoostValveOn = byte(0x1B)
+	// BoostValveOff actuator command
+	BoostValveOff = byte(0x0B)
+	// Fan1On actuator command
+	Fan1On = byte(0x1D)
+	// Fan1Off actuator command
+	Fan1Off = byte(0x0D)
+	// Fan2On actuator command
+	Fan2On = byte(0x1E)
+	// Fan2Off actuator command
+	Fan2Off = byte(0x0E)
+	// TestInjectors actuator command
+	TestInjectors = byte(0xF7)
+	// FireCoil actuator command
+	FireCoil = byte(0xF8)
+	// OpenIAC steps the idle air control valve open
+	OpenIAC = byte(0xFD)
+	// CloseIAC steps the idle air control valve closed
+	CloseIAC = byte(0xFE)
+	// ResetAdjustments resets the adjustable values to their defaults
+	ResetAdjustments = byte(0x0F)
+	// FuelTrimIncrement adjustment command
+	FuelTrimIncrement = byte(0x79)
+	// FuelTrimDecrement adjustment command
+	FuelTrimDecrement = byte(0x7A)
+	// IdleDecayIncrement adjustment command
+	IdleDecayIncrement = byte(0x89)
+	// IdleDecayDecrement adjustment command
+	IdleDecayDecrement = byte(0x8A)
+	// IdleSpeedIncrement adjustment command
+	IdleSpeedIncrement = byte(0x91)
+	// IdleSpeedDecrement adjustment command
+	IdleSpeedDecrement = byte(0x92)
+	// IgnitionAdvanceIncrement adjustment command
+	IgnitionAdvanceIncrement = byte(0x93)
+	// IgnitionAdvanceDecrement adjustment command
+	IgnitionAdvanceDecrement = byte(0x94)
+)
+
+// DefaultECUID returned in response to the ECU id command
+var DefaultECUID = []byte{0x99, 0x00, 0x02, 0x03}
@@ -0,0 +1,191 @@
+package emulator
+
+import (
+	"encoding/hex"
+	"io"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+)
+
+// Emulator speaks the MEMS 1.6 serial protocol, answering the dataframe
+// requests from the scenario
+type Emulator struct {
+	// ECUID returned in response to the ECU id command
+	ECUID []byte
+	// State of the actuators and adjustments
+	State    *State
+	scenario *scenarios.Scenario
+	current  *scenarios.MemsFCRData
+}
+
+// NewEmulator creates a new emulator that replays the scenario, the emulator has
+// its own playback that loops so it can run indefinitely without changing the scenario
+func NewEmulator(scenario *scenarios.Scenario) *Emulator {
+	emulator := &Emulator{}
+	emulator.ECUID = DefaultECUID
+	emulator.State = &State{}
+	emulator.scenario = scenarios.NewScenario()
+	emulator.scenario.Memsdata = scenario.Memsdata
+	emulator.scenario.Count = scenario.Count
+	emulator.scenario.SeekIndex(scenario.GetPosition())
+	emulator.scenario.SetLoop(true)
+
+	return emulator
+}
+
+// Serve reads commands from the port and writes the responses until the port is closed
+func (emulator *Emulator) Serve(port io.ReadWriter) error {
+	command := make([]byte, 1)
+
+	for {
+		if _, err := io.ReadFull(port, command); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+
+		response := emulator.Respond(command[0])
+		utils.LogI.Printf("%s %X", utils.ECUCommandTrace, command)
+
+		if _, err := port.Write(response); err != nil {
+			return err
+		}
+
+		utils.LogI.Printf("%s %X", utils.ECUResponseTrace, response)
+	}
+}
+
+// Respond returns the ECU response to the command, all responses start with an echo of the command
+func (emulator *Emulator) Respond(command byte) []byte {
+	switch command {
+	case InitCommandA, InitCommandB:
+		return []byte{command}
+	case HeartbeatCommand:
+		return []byte{command, 0x00}
+	case ECUIDCommand:
+		return append([]byte{command}, emulator.ECUID...)
+	case Dataframe80Command:
+		emulator.current, _ = emulator.scenario.Next()
+		return emulator.dataframe(command)
+	case Dataframe7dCommand:
+		// the 0x7d dataframe is paired with the last 0x80 dataframe
+		if emulator.current == nil {
+			emulator.current, _ = emulator.scenario.Next()
+		}
+		return emulator.dataframe(command)
+	case ClearFaultsCommand:
+		emulator.State.FaultsCleared = true
+	case TestInjectors:
+		emulator.State.InjectorsTested++
+	case FireCoil:
+		emulator.State.CoilFired++
+	case OpenIAC, CloseIAC:
+		return []byte{command, emulator.stepIAC(command)}
+	case ResetAdjustments:
+		emulator.State.FuelTrim = 0
+		emulator.State.IdleDecay = 0
+		emulator.State.IdleSpeed = 0
+		emulator.State.IgnitionAdvance = 0
+	case FuelTrimIncrement, FuelTrimDecrement, IdleDecayIncrement, IdleDecayDecrement,
+		IdleSpeedIncrement, IdleSpeedDecrement, IgnitionAdvanceIncrement, IgnitionAdvanceDecrement:
+		return []byte{command, emulator.adjust(command)}
+	default:
+		if actuator, ok := emulator.State.actuators()[command]; ok {
+			*actuator = isOnCommand(command)
+		}
+	}
+
+	// fault clear, actuator and unknown commands are acknowledged
+	return []byte{command, 0x00}
+}
+
+// stepIAC moves the IAC one step and returns the new position
+func (emulator *Emulator) stepIAC(command byte) byte {
+	recorded := int(emulator.recordedByte(Dataframe80Command, iacPosition80))
+	steps := emulator.State.IACSteps
+
+	if command == OpenIAC {
+		steps++
+	} else {
+		steps--
+	}
+
+	// don't step beyond the IAC limits
+	position := recorded + steps
+	if position >= 0 && position <= maximumIACPosition {
+		emulator.State.IACSteps = steps
+	}
+
+	return clamp(recorded+emulator.State.IACSteps, 0, maximumIACPosition)
+}
+
+// adjust applies the adjustment command and returns the new value
+func (emulator *Emulator) adjust(command byte) byte {
+	state := emulator.State
+
+	step := 1
+	if command == FuelTrimDecrement || command == IdleDecayDecrement || command == IdleSpeedDecrement || command == IgnitionAdvanceDecrement {
+		step = -1
+	}
+
+	switch command {
+	case FuelTrimIncrement, FuelTrimDecrement:
+		state.FuelTrim += step
+		return adjust(emulator.recordedByte(Dataframe7dCommand, longTermFuelTrim7d), state.FuelTrim)
+	case IdleDecayIncrement, IdleDecayDecrement:
+		state.IdleDecay += step
+		return adjust(defaultIdleDecay, state.IdleDecay)
+	case IdleSpeedIncrement, IdleSpeedDecrement:
+		state.IdleSpeed += step
+		return adjust(emulator.recordedByte(Dataframe7dCommand, idleSpeedOffset7d), state.IdleSpeed)
+	default:
+		state.IgnitionAdvance += step
+		return adjust(emulator.recordedByte(Dataframe7dCommand, ignitionAdvance7d), state.IgnitionAdvance)
+	}
+}
+
+// recordedByte returns the byte from the current recorded dataframe before the state is applied
+func (emulator *Emulator) recordedByte(command byte, position int) byte {
+	if emulator.current == nil {
+		if data, ok := emulator.scenario.Peek(); ok {
+			emulator.current = data
+		} else {
+			return defaultAdjustedValue
+		}
+	}
+
+	dataframe := emulator.current.Dataframe80
+	if command == Dataframe7dCommand {
+		dataframe = emulator.current.Dataframe7d
+	}
+
+	raw, _ := hex.DecodeString(dataframe)
+
+	return getByte(raw, position)
+}
+
+// dataframe returns the raw dataframe, the hex dataframes include the command echo
+func (emulator *Emulator) dataframe(command byte) []byte {
+	if emulator.current == nil {
+		return []byte{command}
+	}
+
+	dataframe := emulator.current.Dataframe80
+	if command == Dataframe7dCommand {
+		dataframe = emulator.current.Dataframe7d
+	}
+
+	response, err := hex.DecodeString(dataframe)
+
+	if err != nil || len(response) == 0 || response[0] != command {
+		utils.LogW.Printf("invalid dataframe %X '%s'", command, dataframe)
+		return []byte{command}
+	}
+
+	emulator.State.apply(response)
+
+	return response
+}
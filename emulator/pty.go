@@ -0,0 +1,28 @@
+package emulator
+
+import (
+	"os"
+
+	"github.com/creack/pty"
+	"golang.org/x/term"
+)
+
+// OpenPseudoTerminal opens a pseudo terminal for the emulator, returns the emulator's end
+// and the tty the diagnostic software connects to by name as if it were a serial port.
+// The caller keeps the tty open so the pseudo terminal remains available between
+// connections from the diagnostic software, and closes both when the emulator stops
+func OpenPseudoTerminal() (*os.File, *os.File, error) {
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// put the port into raw mode so the commands aren't echoed by the terminal
+	if _, err = term.MakeRaw(int(tty.Fd())); err != nil {
+		ptmx.Close()
+		tty.Close()
+		return nil, nil, err
+	}
+
+	return ptmx, tty, nil
+}
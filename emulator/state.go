@@ -0,0 +1,126 @@
+package emulator
+
+// byte positions in the raw dataframes, the first byte is the command
+// echo so 80x12 is at position 0x13
+const (
+	iacPosition80        = 0x13
+	dtc0Position80       = 0x0E
+	dtc1Position80       = 0x0F
+	dtc2Position7d       = 0x06
+	longTermFuelTrim7d   = 0x0C
+	dtc3Position7d       = 0x0F
+	dtc4Position7d       = 0x12
+	ignitionAdvance7d    = 0x13
+	idleSpeedOffset7d    = 0x14
+	dtc5Position7d       = 0x17
+	maximumIACPosition   = 180
+	defaultIdleDecay     = 0x23
+	defaultAdjustedValue = 0x80
+)
+
+// State of the emulated ECU, changed by the actuator and adjustment commands
+type State struct {
+	FuelPump   bool
+	PTCRelay   bool
+	ACRelay    bool
+	PurgeValve bool
+	O2Heater   bool
+	BoostValve bool
+	Fan1       bool
+	Fan2       bool
+	// IACSteps steps the IAC has been moved from the recorded position
+	IACSteps int
+	// adjustments from the recorded values
+	FuelTrim        int
+	IdleDecay       int
+	IdleSpeed       int
+	IgnitionAdvance int
+	// FaultsCleared zeros the fault codes in the dataframes
+	FaultsCleared bool
+	// InjectorsTested and CoilFired count the test commands
+	InjectorsTested int
+	CoilFired       int
+}
+
+// actuators maps the on / off commands to the state they change
+func (state *State) actuators() map[byte]*bool {
+	return map[byte]*bool{
+		FuelPumpOn:    &state.FuelPump,
+		FuelPumpOff:   &state.FuelPump,
+		PTCRelayOn:    &state.PTCRelay,
+		PTCRelayOff:   &state.PTCRelay,
+		ACRelayOn:     &state.ACRelay,
+		ACRelayOff:    &state.ACRelay,
+		PurgeValveOn:  &state.PurgeValve,
+		PurgeValveOff: &state.PurgeValve,
+		O2HeaterOn:    &state.O2Heater,
+		O2HeaterOff:   &state.O2Heater,
+		BoostValveOn:  &state.BoostValve,
+		BoostValveOff: &state.BoostValve,
+		Fan1On:        &state.Fan1,
+		Fan1Off:       &state.Fan1,
+		Fan2On:        &state.Fan2,
+		Fan2Off:       &state.Fan2,
+	}
+}
+
+// isOnCommand returns true for the actuator on commands, the on command
+// is the off command with bit 4 set
+func isOnCommand(command byte) bool {
+	return command&0x10 != 0
+}
+
+// apply the emulated state to the raw dataframe
+func (state *State) apply(dataframe []byte) {
+	switch dataframe[0] {
+	case Dataframe80Command:
+		setByte(dataframe, iacPosition80, clamp(int(getByte(dataframe, iacPosition80))+state.IACSteps, 0, maximumIACPosition))
+
+		if state.FaultsCleared {
+			setByte(dataframe, dtc0Position80, 0)
+			setByte(dataframe, dtc1Position80, 0)
+		}
+	case Dataframe7dCommand:
+		setByte(dataframe, longTermFuelTrim7d, adjust(getByte(dataframe, longTermFuelTrim7d), state.FuelTrim))
+		setByte(dataframe, ignitionAdvance7d, adjust(getByte(dataframe, ignitionAdvance7d), state.IgnitionAdvance))
+		setByte(dataframe, idleSpeedOffset7d, adjust(getByte(dataframe, idleSpeedOffset7d), state.IdleSpeed))
+
+		if state.FaultsCleared {
+			for _, position := range []int{dtc2Position7d, dtc3Position7d, dtc4Position7d, dtc5Position7d} {
+				setByte(dataframe, position, 0)
+			}
+		}
+	}
+}
+
+// getByte returns the byte at the position, 0 if the dataframe is too short
+func getByte(dataframe []byte, position int) byte {
+	if position < len(dataframe) {
+		return dataframe[position]
+	}
+
+	return 0
+}
+
+// setByte sets the byte at the position if the dataframe is long enough
+func setByte(dataframe []byte, position int, value byte) {
+	if position < len(dataframe) {
+		dataframe[position] = value
+	}
+}
+
+func adjust(value byte, adjustment int) byte {
+	return clamp(int(value)+adjustment, 0, 0xFF)
+}
+
+func clamp(value int, min int, max int) byte {
+	if value < min {
+		value = min
+	}
+
+	if value > max {
+		value = max
+	}
+
+	return byte(value)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/andrewdjackson/memscene/generator"
+	"github.com/andrewdjackson/memscene/utils"
+)
+
+// generate a synthetic scenario from the engine model
+//
+// memscene generate [-seed n] [-ambient temp] [-profile profile] [-output file]
+func generate(args []string) {
+	var seed int64
+	var ambient float64
+	var profile string
+	var output string
+
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	flags.Int64Var(&seed, "seed", 1, "random seed, the same seed generates the same scenario")
+	flags.Float64Var(&ambient, "ambient", 10, "ambient temperature (°C)")
+	flags.StringVar(&profile, "profile", "", "drive profile e.g. off:5s,crank:2s,idle:3m,blip:20s,drive:10m")
+	flags.StringVar(&output, "output", "", "destination file")
+	flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		fmt.Println("Usage of ./memscene generate [-seed n] [-ambient temp] [-profile profile] [-output file]")
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+
+	engine := generator.NewGenerator(seed)
+	engine.AmbientTemp = ambient
+
+	if profile != "" {
+		segments, err := generator.ParseProfile(profile)
+		if err != nil {
+			utils.LogE.Fatalf("invalid profile %s", err)
+		}
+		engine.Profile = segments
+	}
+
+	if output == "" {
+		output = fmt.Sprintf("generated-%d.csv", seed)
+	}
+
+	scenario, err := engine.Generate()
+	if err != nil {
+		utils.LogE.Fatalf("unable to generate scenario %s", err)
+	}
+
+	utils.LogI.Printf("generated %d dataframes", scenario.Count)
+
+	scenario.SaveCSVFile(output)
+}
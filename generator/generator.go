@@ -0,0 +1,340 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+)
+
+// The generator produces MEMS 1.6 scenarios from a simple engine and thermal model.
+// The engine is driven through a profile of segments, e.g. cold start, warm up at idle,
+// throttle blips and a drive cycle. The same seed always produces the same scenario.
+
+const (
+	// Off ignition on with the engine stopped
+	Off = "off"
+	// Crank engine on the starter motor
+	Crank = "crank"
+	// Idle engine idling
+	Idle = "idle"
+	// Blip short sharp openings of the throttle at idle
+	Blip = "blip"
+	// Drive varying throttle and engine speed
+	Drive = "drive"
+)
+
+const (
+	thermostatTemp      = 88
+	closedLoopTemp      = 40
+	closedLoopDelay     = 30 * time.Second
+	warmIdleRPM         = 850
+	coldIdleRPM         = 1200
+	crankingRPM         = 200
+	baroPressure        = 100
+	idleMAP             = 32
+	closedThrottleVolts = 0.56
+	lambdaPeriod        = 1.3
+)
+
+// Segment is a period of the drive profile
+type Segment struct {
+	Kind     string
+	Duration time.Duration
+}
+
+// DefaultProfile cold start, warm up at idle, throttle blips, a drive and a hot idle
+var DefaultProfile = []Segment{
+	{Off, 5 * time.Second},
+	{Crank, 2 * time.Second},
+	{Idle, 3 * time.Minute},
+	{Blip, 20 * time.Second},
+	{Idle, 1 * time.Minute},
+	{Drive, 10 * time.Minute},
+	{Idle, 2 * time.Minute},
+	{Off, 5 * time.Second},
+}
+
+// Generator creates synthetic scenarios
+type Generator struct {
+	// Profile of the drive
+	Profile []Segment
+	// AmbientTemp at the start of the scenario
+	AmbientTemp float64
+	// Interval between the dataframes
+	Interval time.Duration
+	// StartTime of the scenario
+	StartTime time.Time
+	seed      int64
+	random    *rand.Rand
+	engine    *engine
+}
+
+// engine is the state of the engine model
+type engine struct {
+	elapsed     time.Duration
+	running     time.Duration
+	coolant     float64
+	airTemp     float64
+	rpm         float64
+	throttle    float64
+	battery     float64
+	longterm    float64
+	closedLoop  bool
+	cranking    bool
+	ignitionOn  bool
+	driveTarget float64
+}
+
+// NewGenerator creates a new generator, the seed makes the scenarios repeatable
+func NewGenerator(seed int64) *Generator {
+	generator := &Generator{}
+	generator.Profile = DefaultProfile
+	generator.AmbientTemp = 10
+	generator.Interval = time.Second
+	generator.StartTime = time.Date(2021, 1, 1, 8, 0, 0, 0, time.UTC)
+	generator.seed = seed
+
+	return generator
+}
+
+// ParseProfile parses a profile in the format kind:duration,kind:duration e.g. off:5s,crank:2s,idle:3m
+func ParseProfile(profile string) ([]Segment, error) {
+	var segments []Segment
+
+	for _, s := range strings.Split(profile, ",") {
+		parts := strings.SplitN(strings.TrimSpace(s), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid segment '%s'", s)
+		}
+
+		switch parts[0] {
+		case Off, Crank, Idle, Blip, Drive:
+		default:
+			return nil, fmt.Errorf("unknown segment '%s'", parts[0])
+		}
+
+		duration, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		segments = append(segments, Segment{Kind: parts[0], Duration: duration})
+	}
+
+	return segments, nil
+}
+
+// Generate runs the engine model through the profile and returns the scenario, the engine and
+// the random numbers are reset from the seed so each call generates the same scenario
+func (generator *Generator) Generate() (*scenarios.Scenario, error) {
+	if generator.Interval <= 0 {
+		return nil, fmt.Errorf("invalid interval %s, the interval must be greater than 0", generator.Interval)
+	}
+
+	scenario := scenarios.NewScenario()
+
+	generator.random = rand.New(rand.NewSource(generator.seed))
+	generator.engine = &engine{
+		coolant:    generator.AmbientTemp,
+		airTemp:    generator.AmbientTemp,
+		battery:    12.4,
+		longterm:   generator.random.NormFloat64() * 2,
+		ignitionOn: true,
+	}
+
+	for _, segment := range generator.Profile {
+		for t := time.Duration(0); t < segment.Duration; t += generator.Interval {
+			generator.step(segment.Kind, t)
+			scenario.Memsdata = append(scenario.Memsdata, generator.dataframes())
+			generator.engine.elapsed += generator.Interval
+		}
+	}
+
+	scenario.Count = len(scenario.Memsdata)
+
+	return scenario, nil
+}
+
+// step advances the engine model by one interval
+func (generator *Generator) step(kind string, t time.Duration) {
+	e := generator.engine
+	dt := generator.Interval.Seconds()
+	noise := generator.random.NormFloat64
+
+	e.cranking = kind == Crank
+	e.throttle = 0
+
+	switch kind {
+	case Off:
+		e.rpm = 0
+		e.running = 0
+		e.closedLoop = false
+	case Crank:
+		e.rpm = crankingRPM + noise()*20
+	case Idle:
+		e.rpm = generator.idleRPM() + noise()*15
+	case Blip:
+		// open the throttle every 5 seconds
+		if int(t.Seconds())%5 == 1 {
+			e.throttle = 0.3 + generator.random.Float64()*0.3
+			e.rpm = generator.idleRPM() + e.throttle*5000
+		} else {
+			e.rpm = generator.idleRPM() + noise()*15
+		}
+	case Drive:
+		// random walk of the throttle towards a target that changes every 20 seconds
+		if int(t.Seconds())%20 == 0 {
+			e.driveTarget = 0.1 + generator.random.Float64()*0.6
+		}
+		e.throttle = math.Max(0, math.Min(1, e.driveTarget+noise()*0.05))
+		e.rpm = 1500 + e.throttle*3500 + noise()*50
+	}
+
+	if e.rpm < 0 {
+		e.rpm = 0
+	}
+
+	isRunning := kind == Idle || kind == Blip || kind == Drive
+	if isRunning {
+		e.running += generator.Interval
+	}
+
+	// engine heats with speed until the thermostat opens, cools slowly when stopped
+	if isRunning && e.coolant < thermostatTemp {
+		e.coolant += (0.08 + e.rpm*0.00004) * dt
+	} else if isRunning {
+		e.coolant = thermostatTemp + noise()*0.5
+	} else {
+		e.coolant -= (e.coolant - generator.AmbientTemp) * 0.0005 * dt
+	}
+
+	// under bonnet heat soak raises the intake air temperature
+	e.airTemp += ((generator.AmbientTemp + (e.coolant-generator.AmbientTemp)*0.25) - e.airTemp) * 0.01 * dt
+
+	switch {
+	case e.cranking:
+		e.battery = 10.2 + noise()*0.2
+	case isRunning:
+		e.battery = 14.1 + noise()*0.05
+	default:
+		e.battery = 12.4 + noise()*0.02
+	}
+
+	e.closedLoop = isRunning && e.coolant > closedLoopTemp && e.running > closedLoopDelay && e.throttle < 0.8
+}
+
+// idleRPM returns the target idle speed, the ECU raises the idle when cold
+func (generator *Generator) idleRPM() float64 {
+	warm := math.Max(0, math.Min(1, (generator.engine.coolant-generator.AmbientTemp)/(80-generator.AmbientTemp)))
+
+	return coldIdleRPM - (coldIdleRPM-warmIdleRPM)*warm
+}
+
+// dataframes builds the raw dataframes from the engine model and decodes them
+func (generator *Generator) dataframes() *scenarios.MemsFCRData {
+	e := generator.engine
+	noise := generator.random.NormFloat64
+	idle := e.throttle == 0 && e.rpm > 0
+
+	mapkpa := baroPressure
+	if e.rpm > 0 && !e.cranking {
+		mapkpa = int(idleMAP + e.throttle*68 + noise()*1.5)
+	}
+
+	// the idle air control opens further when cold
+	iac := 30 + math.Max(0, 80-e.coolant)*0.8 + noise()*2
+
+	advance := 12 + e.throttle*20
+	coiltime := 3.5 * 14 / e.battery
+
+	// closed loop the lambda oscillates about stoichiometric, open loop runs rich
+	lambda := 800.0
+	stft := 100.0
+	if e.closedLoop {
+		phase := 2 * math.Pi * e.elapsed.Seconds() / lambdaPeriod
+		lambda = 450 + 400*math.Sin(phase) + noise()*30
+		stft = 100 - 4*math.Sin(phase)
+	}
+
+	if e.rpm == 0 {
+		lambda = 450
+	}
+
+	df80 := scenarios.DataFrame80{
+		Command:                  0x80,
+		BytesinFrame:             0x1C,
+		EngineRpm:                uint16(e.rpm),
+		CoolantTemp:              toByte(e.coolant + 55),
+		AmbientTemp:              toByte(generator.AmbientTemp + 55),
+		IntakeAirTemp:            toByte(e.airTemp + 55),
+		FuelTemp:                 0xFF,
+		ManifoldAbsolutePressure: toByte(float64(mapkpa)),
+		BatteryVoltage:           toByte(e.battery * 10),
+		ThrottlePotSensor:        toByte((closedThrottleVolts + e.throttle*3.9) / 0.02),
+		IdleSetPoint:             0x20,
+		IdleHot:                  toByte(generator.idleRPM()/10 - 50),
+		IacPosition:              toByte(iac),
+		IgnitionAdvanceOffset80:  5,
+		IgnitionAdvance:          toByte((advance + 24) * 2),
+		CoilTime:                 uint16(coiltime / 0.002),
+	}
+
+	if idle {
+		df80.IdleSwitch = scenarios.IdleSwitchActive
+		df80.IdleSpeedDeviation = uint16(math.Abs(noise() * 30))
+	}
+
+	if e.rpm > 0 {
+		df80.CrankshaftPositionSensor = 1
+	}
+
+	df7d := scenarios.DataFrame7d{
+		Command:                  0x7D,
+		BytesinFrame:             0x20,
+		IgnitionSwitch:           boolToByte(e.ignitionOn),
+		ThrottleAngle:            toByte(e.throttle * 140),
+		AirFuelRatio:             147,
+		LambdaVoltage:            toByte(lambda / 5),
+		LambdaFrequency:          0xFF,
+		LambdaDutyCycle:          0xFF,
+		LambdaStatus:             boolToByte(e.closedLoop),
+		LoopIndicator:            boolToByte(e.closedLoop),
+		LongTermFuelTrim:         toByte(128 + e.longterm),
+		ShortTermFuelTrim:        toByte(stft),
+		IdleBasePos:              toByte(iac * 0.8),
+		IgnitionAdvanceOffset7d:  48,
+		IdleSpeedOffset:          0x80,
+		JackCount:                0x29,
+		CarbonCanisterPurgeValve: 0,
+	}
+
+	time := generator.StartTime.Add(e.elapsed).Format("15:04:05.000")
+
+	return scenarios.DecodeDataframes(time, encode(df80), encode(df7d))
+}
+
+func encode(dataframe interface{}) []byte {
+	var buffer bytes.Buffer
+	_ = binary.Write(&buffer, binary.BigEndian, dataframe)
+
+	return buffer.Bytes()
+}
+
+// toByte rounds and clamps the value into a byte
+func toByte(value float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(value))))
+}
+
+func boolToByte(b bool) uint8 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
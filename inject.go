@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+)
+
+// inject faults into a scenario
+//
+// memscene inject -faults faults.csv [-output file] <scenario>
+func inject(args []string) {
+	var faults string
+	var output string
+
+	flags := flag.NewFlagSet("inject", flag.ExitOnError)
+	flags.StringVar(&faults, "faults", "", "fault injection file")
+	flags.StringVar(&output, "output", "", "destination file")
+	flags.Parse(args)
+
+	if faults == "" || flags.NArg() != 1 {
+		fmt.Println("Usage of ./memscene inject -faults <file> <scenario>")
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+
+	file := flags.Arg(0)
+
+	if output == "" {
+		_, filename := filepath.Split(file)
+		output = fmt.Sprintf("%s.faults.csv", filename)
+	}
+
+	injections, err := scenarios.LoadInjections(faults)
+	if err != nil {
+		utils.LogE.Fatalf("unable to load fault injections %s", err)
+	}
+
+	scenario, err := scenarios.ConvertFile(file)
+	if err != nil {
+		utils.LogE.Fatalf("unable to load scenario %s", err)
+	}
+
+	injected, err := scenario.Inject(injections)
+	if err != nil {
+		utils.LogE.Fatalf("unable to inject faults %s", err)
+	}
+
+	injected.SaveCSVFile(output)
+}
@@ -3,64 +3,183 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"os"
 	"path/filepath"
 
+	"github.com/andrewdjackson/memscene/analysers"
+	"github.com/andrewdjackson/memscene/rules"
 	"github.com/andrewdjackson/memscene/scenarios"
 	"github.com/andrewdjackson/memscene/utils"
 )
 
+// commands available in addition to the default file conversion
+var commands = map[string]func(args []string){
+	"channels": channels,
+	"compare":  compare,
+	"convert":  convert,
+	"diff":     diff,
+	"emulate":  emulate,
+	"generate": generate,
+	"inject":   inject,
+	"serve":    serve,
+	"watch":    watch,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			command(os.Args[2:])
+			return
+		}
+	}
+
 	var file string
 	var output string
+	var format string
+	var analyse bool
+	var analyser string
+	var rulesfile string
 
-	flag.StringVar(&file, "file", "", "file to convert")
-	flag.StringVar(&output, "output", "", "destination file")
+	flag.StringVar(&file, "file", "", "file to convert, - to read from stdin")
+	flag.StringVar(&output, "output", "", "destination file, - to write to stdout, the destination directory for archives")
+	flag.StringVar(&format, "format", scenarios.CSVFormat, "output format (csv, json, ndjson)")
+	flag.BoolVar(&analyse, "analyse", false, "analyse the scenario and print the diagnostic reports")
+	flag.StringVar(&rulesfile, "rules", "", "evaluate the rules in the file against the scenario")
+	flag.StringVar(&analyser, "analyser", "", "run a single analyser (fueltrim, cranking, charging), or the throttle pot sweep check (throttle)")
+	flag.DurationVar(&scenarios.ReadMemsInterval, "readmems-interval", scenarios.ReadMemsInterval, "assumed time between the dataframes of readmems logs, which have no timestamps")
 	flag.Parse()
 
 	if file == "" {
-		fmt.Println("Usage of ./memscene:\n  -file string\n        file to convert\n  -output string\n        destination file\n")
+		flag.Usage()
 		log.Fatalf("")
 	}
 
+	if file != "-" && utils.IsArchive(file) {
+		// each log in the archive is converted into the output directory
+		results, err := scenarios.ConvertArchive(file, output, format)
+		if err != nil {
+			utils.LogE.Fatalf("unable to read archive %s", err)
+		}
+
+		fmt.Print(&scenarios.BatchReport{Results: results})
+		return
+	}
+
 	if output == "" {
-		_, filename := filepath.Split(file)
-		output = fmt.Sprintf("%s.output.csv", filename)
+		if file == "-" {
+			output = "-"
+		} else {
+			_, filename := filepath.Split(file)
+			output = scenarios.OutputName(filename, format)
+		}
+	}
+
+	in := os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			utils.LogE.Fatalf("%s", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	reader, _, err := scenarios.DetectFrameReader(in)
+	if err != nil {
+		utils.LogE.Fatalf("%s", err)
+	}
+
+	// the reports go to stderr when the scenario is written to stdout
+	reports := os.Stdout
+	if output == "-" {
+		reports = os.Stderr
 	}
 
-	filetype := utils.GetFileType(file)
-	utils.LogI.Printf("file identified as '%s' type", filetype)
+	if !analyse && analyser == "" && rulesfile == "" {
+		// nothing needs the whole scenario so stream the conversion
+		writer, out := createWriter(output, format)
+		defer out.Close()
+
+		if _, err := scenarios.Stream(reader, writer); err != nil {
+			utils.LogE.Fatalf("%s", err)
+		}
 
-	if filetype == utils.Unknown {
-		utils.LogE.Fatalf("Unknown file type")
+		printParseReport(reports, reader)
+		return
 	}
 
-	scenario := scenarios.NewScenario()
-
-	// convert the file and exit
-	switch filetype {
-	case utils.ReadMemsFile:
-		utils.LogI.Printf("converting from readmems file to MemsFCR")
-		r := scenarios.NewReadMems()
-		scenario = r.Convert(file)
-	case utils.MemsRoscoFile:
-		utils.LogI.Printf("converting from memsrosco file to MemsFCR")
-		r := scenarios.NewMemsRosco()
-		scenario = r.Convert(file)
-	case utils.MemsRoscoFilev2:
-		utils.LogI.Printf("converting from memsrosco version 2 file to MemsFCR")
-		r := scenarios.NewMemsRoscoV2()
-		scenario = r.Convert(file)
-	case utils.MemsDiagFile:
-		utils.LogW.Printf("unable to process memsdiag files, not enough data")
-	case utils.MemsFCRFile:
-		utils.LogI.Printf("reprocessing MemsFCR file")
-		r := scenarios.NewMemsFCR()
-		scenario = r.Convert(file)
+	scenario, err := scenarios.ReadAll(reader)
+	if err != nil {
+		utils.LogE.Printf("unable to parse file %s", err)
 	}
 
+	printParseReport(reports, reader)
+
 	if scenario.Count > 0 {
-		save := fmt.Sprintf("%s", output)
-		scenario.SaveCSVFile(save)
+		writer, out := createWriter(output, format)
+
+		for _, data := range scenario.Memsdata {
+			writer.Write(data)
+		}
+
+		if err := writer.Close(); err != nil {
+			utils.LogE.Printf("error saving %s %s", output, err)
+		}
+
+		out.Close()
+
+		if analyse {
+			for _, a := range analysers.All() {
+				utils.LogI.Printf("running %s analysis", a.Name())
+				fmt.Fprint(reports, a.Analyse(scenario))
+			}
+		}
+
+		if analyser != "" {
+			if a := analysers.Find(analyser); a != nil {
+				fmt.Fprint(reports, a.Analyse(scenario))
+			} else {
+				utils.LogE.Printf("unknown analyser '%s'", analyser)
+			}
+		}
+
+		if rulesfile != "" {
+			if r, err := rules.LoadRules(rulesfile); err != nil {
+				utils.LogE.Printf("unable to load rules %s", err)
+			} else if report, err := rules.Evaluate(scenario, r); err != nil {
+				utils.LogE.Printf("unable to evaluate rules %s", err)
+			} else {
+				fmt.Fprint(reports, report)
+			}
+		}
+	}
+}
+
+// printParseReport prints the frames dropped and corrupted if the reader reports them
+func printParseReport(w io.Writer, reader scenarios.FrameReader) {
+	if reporter, ok := reader.(scenarios.ParseReporter); ok {
+		fmt.Fprint(w, reporter.Report())
 	}
 }
+
+// createWriter creates the output file, - for stdout, and the writer for the format
+func createWriter(output string, format string) (scenarios.FrameWriter, *os.File) {
+	out := os.Stdout
+
+	if output != "-" {
+		f, err := os.Create(output)
+		if err != nil {
+			utils.LogE.Fatalf("%s", err)
+		}
+		out = f
+	}
+
+	writer, err := scenarios.NewFrameWriter(out, format)
+	if err != nil {
+		utils.LogE.Fatalf("%s", err)
+	}
+
+	return writer, out
+}
@@ -0,0 +1,365 @@
+package rules
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+)
+
+// Expressions are written over the MemsFCRData fields, for example
+//
+// CoolantTemp > 80 && IACPosition > 60 for 10s
+//
+// operators in order of precedence (lowest first)
+// || && ! == != < <= > >= + - * / and parentheses
+// boolean fields are true (1) or false (0), the optional 'for' clause
+// requires the condition to hold for the duration. A condition doesn't match dataframes
+// missing a channel it references
+
+// node is an element of the parsed expression
+type node interface {
+	eval(data *scenarios.MemsFCRData) float64
+}
+
+type number float64
+
+func (n number) eval(data *scenarios.MemsFCRData) float64 {
+	return float64(n)
+}
+
+type field struct {
+	name  string
+	index int
+}
+
+func (f field) eval(data *scenarios.MemsFCRData) float64 {
+	v := reflect.ValueOf(data).Elem().Field(f.index)
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return boolToFloat(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+
+	return 0
+}
+
+type unary struct {
+	op      string
+	operand node
+}
+
+func (u unary) eval(data *scenarios.MemsFCRData) float64 {
+	v := u.operand.eval(data)
+
+	if u.op == "!" {
+		return boolToFloat(v == 0)
+	}
+
+	return -v
+}
+
+type binary struct {
+	op          string
+	left, right node
+}
+
+func (b binary) eval(data *scenarios.MemsFCRData) float64 {
+	l := b.left.eval(data)
+
+	// short circuit the logical operators
+	switch b.op {
+	case "&&":
+		return boolToFloat(l != 0 && b.right.eval(data) != 0)
+	case "||":
+		return boolToFloat(l != 0 || b.right.eval(data) != 0)
+	}
+
+	r := b.right.eval(data)
+
+	switch b.op {
+	case "==":
+		return boolToFloat(l == r)
+	case "!=":
+		return boolToFloat(l != r)
+	case "<":
+		return boolToFloat(l < r)
+	case "<=":
+		return boolToFloat(l <= r)
+	case ">":
+		return boolToFloat(l > r)
+	case ">=":
+		return boolToFloat(l >= r)
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "/":
+		if r == 0 {
+			return 0
+		}
+		return l / r
+	}
+
+	return 0
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// Expression is a compiled rule condition
+type Expression struct {
+	// Source text of the expression
+	Source string
+	// Duration the condition must hold for before it matches
+	Duration time.Duration
+	root     node
+	// fields referenced by the expression
+	fields []string
+}
+
+// Match returns true if the dataframe matches the condition, a condition referencing a channel
+// that is missing from the dataframe doesn't match
+func (expression *Expression) Match(data *scenarios.MemsFCRData) bool {
+	for _, name := range expression.fields {
+		if !data.HasChannel(name) {
+			return false
+		}
+	}
+
+	return expression.root.eval(data) != 0
+}
+
+// Compile parses the expression
+func Compile(source string) (*Expression, error) {
+	tokens, err := tokenise(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	expression := &Expression{Source: source}
+
+	if expression.root, err = p.parseOr(); err != nil {
+		return nil, err
+	}
+
+	if p.peek() == "for" {
+		p.next()
+
+		if expression.Duration, err = time.ParseDuration(p.next()); err != nil {
+			return nil, fmt.Errorf("invalid duration in '%s': %v", source, err)
+		}
+	}
+
+	if p.peek() != "" {
+		return nil, fmt.Errorf("unexpected '%s' in '%s'", p.peek(), source)
+	}
+
+	expression.fields = p.fields
+
+	return expression, nil
+}
+
+// pairedOperators are the two character operators
+var pairedOperators = []string{"&&", "||", "==", "!=", "<=", ">="}
+
+// tokenise splits the expression into numbers, identifiers, durations and operators
+func tokenise(source string) ([]string, error) {
+	var tokens []string
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		case strings.ContainsRune("()+-*/", r):
+			tokens = append(tokens, string(r))
+			i++
+		case strings.ContainsRune("&|=!<>", r):
+			if i+1 < len(runes) && contains(pairedOperators, string(runes[i:i+2])) {
+				tokens = append(tokens, string(runes[i:i+2]))
+				i += 2
+			} else {
+				tokens = append(tokens, string(r))
+				i++
+			}
+		default:
+			return nil, fmt.Errorf("unexpected character '%c' in '%s'", r, source)
+		}
+	}
+
+	return tokens, nil
+}
+
+type parser struct {
+	tokens   []string
+	position int
+	fields   []string
+}
+
+func (p *parser) peek() string {
+	if p.position < len(p.tokens) {
+		return p.tokens[p.position]
+	}
+
+	return ""
+}
+
+func (p *parser) next() string {
+	token := p.peek()
+	p.position++
+
+	return token
+}
+
+// parseLevel parses a left associative binary operator level
+func (p *parser) parseLevel(operators []string, operand func() (node, error)) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for contains(operators, p.peek()) {
+		op := p.next()
+
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		left = binary{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	return p.parseLevel([]string{"||"}, p.parseAnd)
+}
+
+func (p *parser) parseAnd() (node, error) {
+	return p.parseLevel([]string{"&&"}, p.parseNot)
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.peek() == "!" {
+		p.next()
+
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return unary{op: "!", operand: operand}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	return p.parseLevel([]string{"==", "!=", "<", "<=", ">", ">="}, p.parseSum)
+}
+
+func (p *parser) parseSum() (node, error) {
+	return p.parseLevel([]string{"+", "-"}, p.parseProduct)
+}
+
+func (p *parser) parseProduct() (node, error) {
+	return p.parseLevel([]string{"*", "/"}, p.parseUnary)
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peek() == "-" {
+		p.next()
+
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return unary{op: "-", operand: operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	token := p.next()
+
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case token == "(":
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing ')'")
+		}
+
+		return n, nil
+	case token == "true":
+		return number(1), nil
+	case token == "false":
+		return number(0), nil
+	}
+
+	if v, err := strconv.ParseFloat(token, 64); err == nil {
+		return number(v), nil
+	}
+
+	if !contains(p.fields, token) {
+		p.fields = append(p.fields, token)
+	}
+
+	return lookupField(token)
+}
+
+// lookupField resolves the MemsFCRData field, only numeric and boolean fields can be used
+func lookupField(name string) (node, error) {
+	f, ok := reflect.TypeOf(scenarios.MemsFCRData{}).FieldByName(name)
+
+	if !ok || !f.IsExported() || f.Type.Kind() == reflect.String {
+		return nil, fmt.Errorf("unknown field '%s'", name)
+	}
+
+	return field{name: name, index: f.Index[0]}, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
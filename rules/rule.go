@@ -0,0 +1,208 @@
+package rules
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+	"github.com/gocarina/gocsv"
+)
+
+// Rules files are CSV files in the format:
+//
+// name,severity,condition,description
+// warm-high-iac,warning,CoolantTemp > 80 && IACPosition > 60 for 10s,IAC compensating for an air leak or high load
+//
+
+const (
+	// Info severity
+	Info = "info"
+	// Warning severity
+	Warning = "warning"
+	// Fault severity
+	Fault = "fault"
+)
+
+// Rule produces a named finding when the condition matches
+type Rule struct {
+	Name        string `csv:"name"`
+	Severity    string `csv:"severity"`
+	Condition   string `csv:"condition"`
+	Description string `csv:"description"`
+	expression  *Expression
+}
+
+// NewRule creates and compiles a new rule
+func NewRule(name string, severity string, condition string) (*Rule, error) {
+	rule := &Rule{Name: name, Severity: severity, Condition: condition}
+	err := rule.compile()
+
+	return rule, err
+}
+
+// Severities are the valid rule severities
+var Severities = []string{Info, Warning, Fault}
+
+// compile checks the severity and compiles the condition
+func (rule *Rule) compile() error {
+	var err error
+
+	if !contains(Severities, rule.Severity) {
+		return fmt.Errorf("rule '%s': unknown severity '%s', expected one of %s", rule.Name, rule.Severity, strings.Join(Severities, ", "))
+	}
+
+	if rule.expression, err = Compile(rule.Condition); err != nil {
+		return fmt.Errorf("rule '%s': %v", rule.Name, err)
+	}
+
+	return nil
+}
+
+// LoadRules reads and compiles the rules from the file
+func LoadRules(filepath string) ([]*Rule, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []*Rule
+
+	if err = gocsv.Unmarshal(file, &rules); err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		rule.Severity = strings.ToLower(strings.TrimSpace(rule.Severity))
+
+		if err = rule.compile(); err != nil {
+			return nil, err
+		}
+	}
+
+	utils.LogI.Printf("loaded %d rules from %s", len(rules), filepath)
+
+	return rules, nil
+}
+
+// TimeRange is a period in the scenario where a rule matched
+type TimeRange struct {
+	Start    string
+	End      string
+	Duration time.Duration
+}
+
+// Finding lists where a rule matched
+type Finding struct {
+	Rule    *Rule
+	Matches []TimeRange
+}
+
+// Report is the result of evaluating the rules over a scenario
+type Report struct {
+	Findings []*Finding
+}
+
+// tracks the evaluation of a rule as the scenario is processed
+type ruleState struct {
+	finding *Finding
+	active  bool
+	start   int
+}
+
+// Evaluate runs all the rules over the scenario in a single pass, rules that haven't been
+// compiled by NewRule or LoadRules are compiled first
+func Evaluate(scenario *scenarios.Scenario, rules []*Rule) (*Report, error) {
+	report := &Report{}
+	elapsed := scenario.Elapsed()
+	states := make([]*ruleState, len(rules))
+
+	for i, rule := range rules {
+		if rule.expression == nil {
+			if err := rule.compile(); err != nil {
+				return nil, err
+			}
+		}
+
+		states[i] = &ruleState{finding: &Finding{Rule: rule}}
+		report.Findings = append(report.Findings, states[i].finding)
+	}
+
+	for i, data := range scenario.Memsdata {
+		for _, state := range states {
+			match := state.finding.Rule.expression.Match(data)
+
+			if match && !state.active {
+				state.active = true
+				state.start = i
+			}
+
+			if !match && state.active {
+				state.active = false
+				state.addMatch(scenario, elapsed, i-1)
+			}
+		}
+	}
+
+	for _, state := range states {
+		if state.active {
+			state.addMatch(scenario, elapsed, len(scenario.Memsdata)-1)
+		}
+	}
+
+	return report, nil
+}
+
+// addMatch records the time range if the condition held for long enough, the condition
+// holds until the dataframe after the end
+func (state *ruleState) addMatch(scenario *scenarios.Scenario, elapsed []time.Duration, end int) {
+	duration := elapsed[end] - elapsed[state.start] + sampleInterval(elapsed, end)
+
+	if duration < state.finding.Rule.expression.Duration {
+		return
+	}
+
+	state.finding.Matches = append(state.finding.Matches, TimeRange{
+		Start:    scenario.Memsdata[state.start].Time,
+		End:      scenario.Memsdata[end].Time,
+		Duration: duration,
+	})
+}
+
+// sampleInterval returns the time to the dataframe after the index, the mean interval
+// between the dataframes for the last dataframe
+func sampleInterval(elapsed []time.Duration, index int) time.Duration {
+	if index+1 < len(elapsed) {
+		return elapsed[index+1] - elapsed[index]
+	}
+
+	if index > 0 {
+		return elapsed[index] / time.Duration(index)
+	}
+
+	return 0
+}
+
+// String formats the report for display
+func (report *Report) String() string {
+	var sb strings.Builder
+
+	sb.WriteString("rules report\n")
+
+	for _, finding := range report.Findings {
+		if len(finding.Matches) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "  [%s] %s: %s (%d matches)\n", finding.Rule.Severity, finding.Rule.Name, finding.Rule.Description, len(finding.Matches))
+
+		for _, match := range finding.Matches {
+			fmt.Fprintf(&sb, "    %s - %s (%s)\n", match.Start, match.End, match.Duration)
+		}
+	}
+
+	return sb.String()
+}
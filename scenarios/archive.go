@@ -0,0 +1,58 @@
+package scenarios
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/andrewdjackson/memscene/utils"
+)
+
+// ConvertArchive converts each log in the zip or tar archive into the output directory,
+// the output names are derived from the member names. Members that aren't logs are skipped,
+// members with names outside the output directory fail
+func ConvertArchive(source string, out string, format string) ([]*BatchResult, error) {
+	var results []*BatchResult
+
+	err := utils.WalkArchive(source, func(name string, r io.Reader) error {
+		result := &BatchResult{Source: source + string(filepath.Separator) + name}
+		results = append(results, result)
+
+		member, err := memberName(name)
+		if err != nil {
+			utils.LogW.Printf("unable to convert %s %s", result.Source, err)
+			result.Status = Failed
+			result.Err = err
+			return nil
+		}
+
+		result.Source = filepath.Join(source, member)
+		result.Destination = filepath.Join(out, OutputName(member, format))
+		result.convertReader(r, format)
+
+		return nil
+	})
+
+	return results, err
+}
+
+// memberName cleans the name of the archive member, absolute names and names containing ..
+// would write outside the output directory and are rejected
+func memberName(name string) (string, error) {
+	slashed := strings.ReplaceAll(name, `\`, "/")
+
+	for _, element := range strings.Split(slashed, "/") {
+		if element == ".." {
+			return "", fmt.Errorf("archive member name %q contains ..", name)
+		}
+	}
+
+	cleaned := filepath.FromSlash(path.Clean(slashed))
+	if path.IsAbs(slashed) || filepath.IsAbs(cleaned) || !filepath.IsLocal(cleaned) {
+		return "", fmt.Errorf("archive member name %q is not a relative path", name)
+	}
+
+	return cleaned, nil
+}
@@ -0,0 +1,220 @@
+package scenarios
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/andrewdjackson/memscene/utils"
+)
+
+const (
+	// Converted the file was converted
+	Converted = "converted"
+	// Skipped the file type wasn't recognised or can't be converted
+	Skipped = "skipped"
+	// Failed the file couldn't be converted
+	Failed = "failed"
+)
+
+// BatchResult is the result of converting a file in a batch
+type BatchResult struct {
+	Source      string
+	Destination string
+	FileType    string
+	Count       int
+	Status      string
+	// Reason the file was skipped
+	Reason string
+	Err    error
+}
+
+// BatchReport is the results of converting a directory
+type BatchReport struct {
+	Results []*BatchResult
+}
+
+// ConvertDirectory converts every recognised file in the directory and its subdirectories
+// using a pool of workers. The converted files are written to the output directory keeping
+// the directory structure, with the same naming as single file conversions. Compressed
+// logs are decompressed and each log in an archive is converted. The converted files
+// aren't converted again when the output directory is inside the directory
+func ConvertDirectory(dir string, out string, workers int) (*BatchReport, error) {
+	var files []string
+
+	root, _ := filepath.Abs(dir)
+	output, _ := filepath.Abs(out)
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if abs, _ := filepath.Abs(path); entry.IsDir() && abs == output && abs != root {
+			return filepath.SkipDir
+		}
+
+		// the converted files are written alongside the logs when the output is the directory
+		if output == root && strings.HasSuffix(path, OutputName("", CSVFormat)) {
+			return nil
+		}
+
+		if entry.Type().IsRegular() {
+			files = append(files, path)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan string)
+	results := make(chan []*BatchResult)
+	report := &BatchReport{}
+	wg := sync.WaitGroup{}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for file := range jobs {
+				results <- convertBatchFile(dir, file, out)
+			}
+		}()
+	}
+
+	go func() {
+		for _, file := range files {
+			jobs <- file
+		}
+
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	for converted := range results {
+		report.Results = append(report.Results, converted...)
+	}
+
+	sort.Slice(report.Results, func(i, j int) bool { return report.Results[i].Source < report.Results[j].Source })
+
+	return report, nil
+}
+
+// convertBatchFile converts the file or each log in the archive, keeping the directory structure
+func convertBatchFile(dir string, file string, out string) []*BatchResult {
+	relative, _ := filepath.Rel(dir, file)
+	directory, filename := filepath.Split(relative)
+
+	if utils.IsArchive(file) {
+		results, err := ConvertArchive(file, filepath.Join(out, directory), CSVFormat)
+		if err != nil {
+			utils.LogW.Printf("unable to read archive %s %s", file, err)
+			results = append(results, &BatchResult{Source: file, FileType: utils.ArchiveFile, Status: Failed, Err: err})
+		}
+
+		return results
+	}
+
+	result := &BatchResult{
+		Source:      file,
+		Destination: filepath.Join(out, directory, OutputName(filename, CSVFormat)),
+	}
+
+	r, err := os.Open(file)
+	if err != nil {
+		result.Status = Failed
+		result.Err = err
+		return []*BatchResult{result}
+	}
+	defer r.Close()
+
+	result.convertReader(r, CSVFormat)
+
+	return []*BatchResult{result}
+}
+
+// convertReader detects the log type and converts the log, unknown logs and memsdiag logs
+// are skipped
+func (result *BatchResult) convertReader(r io.Reader, format string) {
+	reader, filetype, err := DetectFrameReader(r)
+	result.FileType = filetype
+
+	switch filetype {
+	case utils.Unknown:
+		result.Status = Skipped
+		result.Reason = "file type not recognised"
+		return
+	case utils.MemsDiagFile:
+		result.Status = Skipped
+		result.Reason = "memsdiag logs don't have enough data to convert"
+		return
+	}
+
+	if err == nil {
+		result.Count, err = streamTo(reader, result.Destination, format)
+		logParseReport(result.Source, reader)
+	}
+
+	if err != nil {
+		utils.LogW.Printf("unable to convert %s %s", result.Source, err)
+		result.Status = Failed
+		result.Err = err
+		return
+	}
+
+	result.Status = Converted
+}
+
+// Count returns the number of files with the status
+func (report *BatchReport) Count(status string) int {
+	count := 0
+
+	for _, result := range report.Results {
+		if result.Status == status {
+			count++
+		}
+	}
+
+	return count
+}
+
+// String formats the report as a table
+func (report *BatchReport) String() string {
+	var sb strings.Builder
+
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "FILE\tTYPE\tFRAMES\tSTATUS\n")
+
+	for _, result := range report.Results {
+		status := result.Status
+		if result.Err != nil {
+			status = fmt.Sprintf("%s: %s", status, result.Err)
+		} else if result.Reason != "" {
+			status = fmt.Sprintf("%s: %s", status, result.Reason)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", result.Source, result.FileType, result.Count, status)
+	}
+
+	w.Flush()
+
+	fmt.Fprintf(&sb, "\n%d converted, %d failed, %d skipped\n", report.Count(Converted), report.Count(Failed), report.Count(Skipped))
+
+	return sb.String()
+}
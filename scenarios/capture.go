@@ -0,0 +1,305 @@
+package scenarios
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/andrewdjackson/memscene/utils"
+)
+
+// Serial captures are the raw bytes sniffed from the serial line. The capture starts with
+// the header MEMSCAP1 followed by records of the bytes in the order they were read:
+//
+// timestamp  uint64 big endian, microseconds since the unix epoch
+// length     uint16 big endian
+// data       the bytes read
+//
+// The line carries the requests, the command echoes and the responses. The ECU echoes the
+// command before the response so the 0x80 and 0x7d frames are found by their headers,
+// the command followed by the BytesinFrame, 0x80 0x1C and 0x7D 0x20 or the lengths of the
+// registered variants. The ECU ID is read from the response to the D0 command, 0xD0 0xD0
+// followed by the 4 byte ID, and everything else in the stream is skipped. Captures of MEMS 1.9
+// and MEMS 2J ECUs carry KWP2000 messages, their live data layouts aren't known and the
+// captures are rejected.
+
+const (
+	// frameTimeout is the longest the ECU takes to send a frame, a frame spread over a
+	// longer time has lost bytes and the following bytes belong to the next response
+	frameTimeout = 200 * time.Millisecond
+	// recordHeaderSize is the size of the timestamp and length of a record
+	recordHeaderSize = 10
+	// captureDetectionSize is the amount of the capture read to identify the ECU
+	captureDetectionSize = 4096
+)
+
+// captureStream reads the records of the capture into a buffer of the unprocessed bytes
+// and the time each byte was read
+type captureStream struct {
+	reader io.Reader
+	buffer []byte
+	times  []time.Time
+	offset int
+	eof    bool
+	report *ParseReport
+}
+
+// ecuIDHeader is the D0 command followed by the ECU's echo, the response is the 4 byte ECU ID
+var ecuIDHeader = []byte{0xD0, 0xD0}
+
+const ecuIDResponseSize = 6
+
+// captureReader pairs the 0x80 and 0x7d frames in the capture into dataframes
+type captureReader struct {
+	*captureStream
+	frame80 []byte
+	time80  time.Time
+	headers [][]byte
+	ecuID   string
+}
+
+// newCaptureReader identifies the ECU from the start of the capture and returns the reader for its protocol
+func newCaptureReader(r io.Reader) (FrameReader, error) {
+	header := make([]byte, len(utils.SerialCaptureHeader))
+
+	if _, err := io.ReadFull(r, header); err != nil || string(header) != utils.SerialCaptureHeader {
+		return nil, fmt.Errorf("invalid serial capture header")
+	}
+
+	stream := &captureStream{reader: r, offset: len(header), report: &ParseReport{}}
+
+	if err := stream.fill(captureDetectionSize); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if address, ok := findKWPResponse(stream.buffer); ok {
+		return nil, fmt.Errorf("KWP2000 capture from ECU address 0x%02X, the live data layout of MEMS 1.9 and MEMS 2J ECUs isn't available", address)
+	}
+
+	reader := &captureReader{captureStream: stream}
+
+	lengths80, lengths7d := frameLengths()
+	for _, length := range lengths80 {
+		reader.headers = append(reader.headers, []byte{0x80, length})
+	}
+	for _, length := range lengths7d {
+		reader.headers = append(reader.headers, []byte{0x7D, length})
+	}
+
+	reader.headers = append(reader.headers, ecuIDHeader)
+
+	return reader, nil
+}
+
+// Report returns the frames dropped and corrupted in the capture so far
+func (stream *captureStream) Report() *ParseReport {
+	return stream.report
+}
+
+// Read returns the dataframe when a 0x7d frame follows a 0x80 frame
+func (reader *captureReader) Read() (*MemsFCRData, error) {
+	for {
+		frame, t, err := reader.nextFrame()
+		if err == io.EOF && reader.frame80 != nil {
+			reader.report.Dropped++
+			reader.report.problem("0x80 frame at %s without a 0x7d frame at the end of the capture", formatTime(reader.time80))
+			reader.frame80 = nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if frame[0] == 0x80 {
+			if reader.frame80 != nil {
+				reader.report.Dropped++
+				reader.report.problem("0x80 frame at %s without a 0x7d frame", formatTime(reader.time80))
+			}
+
+			reader.frame80, reader.time80 = frame, t
+			continue
+		}
+
+		if reader.frame80 == nil {
+			reader.report.Dropped++
+			reader.report.problem("0x7d frame at %s without a 0x80 frame", formatTime(t))
+			continue
+		}
+
+		variant := FindVariant(reader.ecuID, reader.frame80, frame)
+		data, missing := variant.Decode(formatTime(reader.time80), reader.frame80, frame)
+		reader.frame80 = nil
+		reader.report.decoded(missing)
+
+		return data, nil
+	}
+}
+
+// nextFrame resynchronises on the next frame header and returns the frame and the time it started,
+// frames that are truncated are reported and skipped
+func (reader *captureReader) nextFrame() ([]byte, time.Time, error) {
+	for {
+		if err := reader.fill(2); err != nil {
+			if err == io.EOF {
+				reader.skip(len(reader.buffer))
+			}
+			return nil, time.Time{}, err
+		}
+
+		start := reader.findHeader()
+		if start < 0 {
+			// keep the last byte, it may be the start of a header
+			reader.skip(len(reader.buffer) - 1)
+			continue
+		}
+
+		reader.skip(start)
+
+		if bytes.HasPrefix(reader.buffer, ecuIDHeader) {
+			if err := reader.readECUID(); err != nil {
+				return nil, time.Time{}, err
+			}
+			continue
+		}
+
+		// the frame is the command followed by BytesinFrame bytes including the length byte
+		length := int(reader.buffer[1]) + 1
+
+		err := reader.fill(length)
+		if err == io.EOF {
+			reader.report.Corrupted++
+			reader.report.problem("0x%02X frame at %s truncated at the end of the capture, %d of %d bytes", reader.buffer[0], formatTime(reader.times[0]), len(reader.buffer), length)
+			reader.skip(len(reader.buffer))
+			return nil, time.Time{}, io.EOF
+		}
+
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		if received := reader.received(length); received < length {
+			// bytes were lost, resynchronise after the frame header
+			reader.report.Corrupted++
+			reader.report.problem("0x%02X frame at %s truncated, %d of %d bytes", reader.buffer[0], formatTime(reader.times[0]), received, length)
+			reader.skip(2)
+			continue
+		}
+
+		frame := make([]byte, length)
+		copy(frame, reader.buffer)
+		t := reader.times[0]
+		reader.consume(length)
+
+		return frame, t, nil
+	}
+}
+
+// readECUID reads the response to the D0 command, the ECU ID identifies the firmware variant.
+// The response isn't a frame so it's counted as skipped
+func (reader *captureReader) readECUID() error {
+	err := reader.fill(ecuIDResponseSize)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if err == io.EOF || reader.received(ecuIDResponseSize) < ecuIDResponseSize {
+		// not a complete response, resynchronise after the command
+		reader.skip(1)
+		return nil
+	}
+
+	reader.ecuID = hex.EncodeToString(reader.buffer[2:ecuIDResponseSize])
+	utils.LogI.Printf("capture ECU ID %s", reader.ecuID)
+
+	reader.skip(ecuIDResponseSize)
+
+	return nil
+}
+
+// findHeader returns the index of the first frame header in the buffer
+func (reader *captureReader) findHeader() int {
+	first := -1
+
+	for _, header := range reader.headers {
+		if i := bytes.Index(reader.buffer, header); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+
+	return first
+}
+
+// received returns the number of bytes of the frame read within the frame timeout
+func (stream *captureStream) received(length int) int {
+	for i := 1; i < length; i++ {
+		if stream.times[i].Sub(stream.times[0]) > frameTimeout {
+			return i
+		}
+	}
+
+	return length
+}
+
+// fill reads records until the buffer holds n bytes, returns io.EOF if the capture ends first
+func (stream *captureStream) fill(n int) error {
+	for len(stream.buffer) < n {
+		if stream.eof {
+			return io.EOF
+		}
+
+		if err := stream.readRecord(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readRecord appends the bytes of the next record to the buffer
+func (stream *captureStream) readRecord() error {
+	var header [recordHeaderSize]byte
+
+	_, err := io.ReadFull(stream.reader, header[:])
+	if err == io.EOF {
+		stream.eof = true
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("truncated record at offset %d", stream.offset)
+	}
+
+	t := time.UnixMicro(int64(binary.BigEndian.Uint64(header[0:8])))
+	data := make([]byte, binary.BigEndian.Uint16(header[8:10]))
+
+	if _, err = io.ReadFull(stream.reader, data); err != nil {
+		return fmt.Errorf("truncated record at offset %d", stream.offset)
+	}
+
+	stream.offset += recordHeaderSize + len(data)
+	stream.buffer = append(stream.buffer, data...)
+
+	for range data {
+		stream.times = append(stream.times, t)
+	}
+
+	return nil
+}
+
+// skip discards bytes that aren't part of a frame
+func (stream *captureStream) skip(n int) {
+	stream.report.Skipped += n
+	stream.consume(n)
+}
+
+func (stream *captureStream) consume(n int) {
+	stream.buffer = stream.buffer[n:]
+	stream.times = stream.times[n:]
+}
+
+func formatTime(t time.Time) string {
+	return t.Format("15:04:05.000")
+}
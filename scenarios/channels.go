@@ -0,0 +1,164 @@
+package scenarios
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// Channel describes a MemsFCRData field, the value is decoded from the source bytes
+// of the MEMS 1.6 raw dataframes as raw * Scale + Offset
+type Channel struct {
+	// Name of the MemsFCRData field
+	Name string `json:"name"`
+	// Column in the CSV files
+	Column string `json:"column"`
+	// DisplayName for reports and plots
+	DisplayName string `json:"displayName"`
+	// Unit of the value, empty for flags, counts and raw values
+	Unit string `json:"unit"`
+	// Source bytes in the raw dataframes, e.g. 80x01-02 are bytes 1 and 2 of the 0x80 dataframe
+	Source string `json:"source"`
+	// Scale and Offset convert the raw bytes into the value
+	Scale  float64 `json:"scale"`
+	Offset float64 `json:"offset"`
+	// Min and Max are the range of valid values
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	// Description of the channel
+	Description string `json:"description"`
+}
+
+// Channels in the order of the MemsFCRData fields, the columns are set from the CSV tags
+var Channels = []*Channel{
+	{"Time", "", "Time", "", "", 0, 0, 0, 0, "time the dataframe was read, hh:mm:ss.000"},
+	{"EngineRPM", "", "Engine Speed", "rpm", "80x01-02", 1, 0, 0, 8000, "engine speed"},
+	{"CoolantTemp", "", "Coolant Temperature", "°C", "80x03", 1, -55, -55, 200, "coolant temperature"},
+	{"AmbientTemp", "", "Ambient Temperature", "°C", "80x04", 1, -55, -55, 200, "ambient temperature, not fitted on all vehicles"},
+	{"IntakeAirTemp", "", "Intake Air Temperature", "°C", "80x05", 1, -55, -55, 200, "intake air temperature"},
+	{"FuelTemp", "", "Fuel Temperature", "°C", "80x06", 1, -55, -55, 200, "fuel temperature, 0xFF when not fitted"},
+	{"ManifoldAbsolutePressure", "", "Manifold Absolute Pressure", "kPa", "80x07", 1, 0, 0, 255, "manifold absolute pressure, the engine load"},
+	{"BatteryVoltage", "", "Battery Voltage", "V", "80x08", 0.1, 0, 0, 25.5, "battery voltage"},
+	{"ThrottlePotSensor", "", "Throttle Pot Voltage", "V", "80x09", 0.02, 0, 0, 5.1, "throttle potentiometer voltage"},
+	{"IdleSwitch", "", "Idle Switch", "", "80x0A", 1, 0, 0, 1, "idle switch, bit 3 set when the throttle is closed"},
+	{"AirconSwitch", "", "Aircon Switch", "", "80x0B", 1, 0, 0, 1, "air conditioning switch"},
+	{"ParkNeutralSwitch", "", "Park Neutral Switch", "", "80x0C", 1, 0, 0, 1, "park or neutral selected on automatic gearboxes"},
+	{"DTC0", "", "Fault Codes 0", "", "80x0D", 1, 0, 0, 255, "fault codes, bit 0 coolant temperature sensor, bit 1 intake air temperature sensor"},
+	{"DTC1", "", "Fault Codes 1", "", "80x0E", 1, 0, 0, 255, "fault codes, bit 1 fuel pump circuit, bit 7 throttle pot circuit"},
+	{"IdleSetPoint", "", "Idle Set Point", "", "80x0F", 1, 0, 0, 255, "idle speed set point"},
+	{"IdleHot", "", "Idle Hot", "", "80x10", 1, -35, -35, 220, "idle decay when hot"},
+	{"Uk8011", "", "Unknown 80x11", "", "80x11", 1, 0, 0, 255, "unknown"},
+	{"IACPosition", "", "Idle Air Control Position", "steps", "80x12", 1, 0, 0, 180, "idle air control stepper motor position, 0 closed"},
+	{"IdleSpeedDeviation", "", "Idle Speed Deviation", "rpm", "80x13-14", 1, 0, 0, 65535, "difference between the idle speed and the target idle speed"},
+	{"IgnitionAdvanceOffset80", "", "Ignition Advance Offset", "°", "80x15", 1, 0, 0, 255, "ignition advance offset"},
+	{"IgnitionAdvance", "", "Ignition Advance", "°", "80x16", 0.5, -24, -24, 103.5, "ignition advance before top dead centre"},
+	{"CoilTime", "", "Coil Time", "ms", "80x17-18", 0.002, 0, 0, 131.07, "ignition coil charge time"},
+	{"CrankshaftPositionSensor", "", "Crankshaft Position Sensor", "", "80x19", 1, 0, 0, 1, "crankshaft position sensor signal detected"},
+	{"Uk801a", "", "Unknown 80x1A", "", "80x1A", 1, 0, 0, 255, "unknown"},
+	{"Uk801b", "", "Unknown 80x1B", "", "80x1B", 1, 0, 0, 255, "unknown"},
+	{"IgnitionSwitch", "", "Ignition Switch", "", "7dx01", 1, 0, 0, 1, "ignition switched on"},
+	{"ThrottleAngle", "", "Throttle Angle", "°", "7dx02", 0.6, 0, 0, 153, "throttle angle"},
+	{"Uk7d03", "", "Unknown 7dx03", "", "7dx03", 1, 0, 0, 255, "unknown"},
+	{"AirFuelRatio", "", "Air Fuel Ratio", ":1", "7dx04", 0.1, 0, 0, 25.5, "air fuel ratio"},
+	{"DTC2", "", "Fault Codes 2", "", "7dx05", 1, 0, 0, 255, "fault codes"},
+	{"LambdaVoltage", "", "Lambda Voltage", "mV", "7dx06", 5, 0, 0, 1275, "lambda sensor voltage, rich above 450mV"},
+	{"LambdaFrequency", "", "Lambda Frequency", "", "7dx07", 1, 0, 0, 255, "lambda sensor frequency, 0xFF when not used"},
+	{"LambdaDutycycle", "", "Lambda Duty Cycle", "", "7dx08", 1, 0, 0, 255, "lambda sensor duty cycle, 0xFF when not used"},
+	{"LambdaStatus", "", "Lambda Status", "", "7dx09", 1, 0, 0, 255, "lambda sensor status, 1 when the sensor is active"},
+	{"ClosedLoop", "", "Closed Loop", "", "7dx0A", 1, 0, 0, 1, "fuelling is in closed loop using the lambda sensor"},
+	{"LongTermFuelTrim", "", "Long Term Fuel Trim", "%", "7dx0B", 1, -128, -128, 127, "long term fuel trim"},
+	{"ShortTermFuelTrim", "", "Short Term Fuel Trim", "%", "7dx0C", 1, 0, 0, 255, "short term fuel trim"},
+	{"CarbonCanisterPurgeValve", "", "Carbon Canister Purge Valve", "%", "7dx0D", 1, 0, 0, 255, "carbon canister purge valve duty cycle"},
+	{"DTC3", "", "Fault Codes 3", "", "7dx0E", 1, 0, 0, 255, "fault codes"},
+	{"IdleBasePosition", "", "Idle Base Position", "steps", "7dx0F", 1, 0, 0, 255, "idle air control base position"},
+	{"Uk7d10", "", "Unknown 7dx10", "", "7dx10", 1, 0, 0, 255, "unknown"},
+	{"DTC4", "", "Fault Codes 4", "", "7dx11", 1, 0, 0, 255, "fault codes"},
+	{"IgnitionAdvanceOffset7d", "", "Ignition Advance Offset 2", "°", "7dx12", 1, -48, -48, 207, "ignition advance offset"},
+	{"IdleSpeedOffset", "", "Idle Speed Offset", "rpm", "7dx13", 25, -3200, -3200, 3175, "idle speed offset"},
+	{"Uk7d14", "", "Unknown 7dx14", "", "7dx14", 1, 0, 0, 255, "unknown, may be the idle error"},
+	{"Uk7d15", "", "Unknown 7dx15", "", "7dx15", 1, 0, 0, 255, "unknown"},
+	{"DTC5", "", "Fault Codes 5", "", "7dx16", 1, 0, 0, 255, "fault codes"},
+	{"Uk7d17", "", "Unknown 7dx17", "", "7dx17", 1, 0, 0, 255, "unknown"},
+	{"Uk7d18", "", "Unknown 7dx18", "", "7dx18", 1, 0, 0, 255, "unknown"},
+	{"Uk7d19", "", "Unknown 7dx19", "", "7dx19", 1, 0, 0, 255, "unknown"},
+	{"Uk7d1a", "", "Unknown 7dx1A", "", "7dx1A", 1, 0, 0, 255, "unknown"},
+	{"Uk7d1b", "", "Unknown 7dx1B", "", "7dx1B", 1, 0, 0, 255, "unknown"},
+	{"Uk7d1c", "", "Unknown 7dx1C", "", "7dx1C", 1, 0, 0, 255, "unknown"},
+	{"Uk7d1d", "", "Unknown 7dx1D", "", "7dx1D", 1, 0, 0, 255, "unknown"},
+	{"Uk7d1e", "", "Unknown 7dx1E", "", "7dx1E", 1, 0, 0, 255, "unknown"},
+	{"JackCount", "", "Jack Count", "", "7dx1F", 1, 0, 0, 255, "jack count"},
+	{"Dataframe7d", "", "0x7d Dataframe", "", "7dx00-1F", 0, 0, 0, 0, "raw 0x7d dataframe, hex encoded"},
+	{"Dataframe80", "", "0x80 Dataframe", "", "80x00-1B", 0, 0, 0, 0, "raw 0x80 dataframe, hex encoded"},
+}
+
+var channelsByName = make(map[string]*Channel)
+
+func init() {
+	// the columns are the CSV tags so the registry always matches the files
+	fields := reflect.TypeOf(MemsFCRData{})
+
+	for _, channel := range Channels {
+		field, _ := fields.FieldByName(channel.Name)
+
+		if column := field.Tag.Get("csv"); column != "-" {
+			channel.Column = column
+		}
+
+		channelsByName[channel.Name] = channel
+	}
+}
+
+// FindChannel returns the channel with the MemsFCRData field name, nil if there isn't one
+func FindChannel(name string) *Channel {
+	return channelsByName[name]
+}
+
+// Columns returns the CSV columns in order, channels that aren't written are excluded
+func Columns() []string {
+	var columns []string
+
+	for _, channel := range Channels {
+		if channel.Column != "" {
+			columns = append(columns, channel.Column)
+		}
+	}
+
+	return columns
+}
+
+// Label returns the display name and unit, e.g. for the axis of a plot
+func (channel *Channel) Label() string {
+	if channel.Unit == "" {
+		return channel.DisplayName
+	}
+
+	return fmt.Sprintf("%s (%s)", channel.DisplayName, channel.Unit)
+}
+
+// Scaling describes the conversion of the raw bytes into the value
+func (channel *Channel) Scaling() string {
+	if channel.Scale == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("x")
+
+	if channel.Scale != 1 {
+		fmt.Fprintf(&sb, " * %g", channel.Scale)
+	}
+
+	if channel.Offset < 0 {
+		fmt.Fprintf(&sb, " - %g", math.Abs(channel.Offset))
+	} else if channel.Offset > 0 {
+		fmt.Fprintf(&sb, " + %g", channel.Offset)
+	}
+
+	return sb.String()
+}
+
+// InRange returns true if the value is within the valid range of the channel
+func (channel *Channel) InRange(value float64) bool {
+	return value >= channel.Min && value <= channel.Max
+}
@@ -0,0 +1,136 @@
+package scenarios
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/andrewdjackson/memscene/utils"
+)
+
+// ConvertFile identifies the type of log file and converts it into a MemsFCR scenario, if the
+// log can't be parsed to the end the dataframes read before the error are returned with the error
+func ConvertFile(filepath string) (*Scenario, error) {
+	reader, file, err := OpenFrameReader(filepath)
+	if err != nil {
+		return NewScenario(), err
+	}
+	defer file.Close()
+
+	scenario, err := ReadAll(reader)
+
+	logParseReport(filepath, reader)
+
+	utils.LogI.Printf("loaded scenario %s (%d dataframes)", filepath, scenario.Count)
+
+	return scenario, err
+}
+
+// LoadFile loads the log as it was written, the values in MemsFCR CSV files are read from
+// their columns rather than decoded again from the raw dataframes so converted files can be
+// compared. Other logs are converted
+func LoadFile(filepath string) (*Scenario, error) {
+	if utils.GetFileType(filepath) != utils.MemsFCRFile {
+		return ConvertFile(filepath)
+	}
+
+	file, err := os.Open(filepath)
+	if err != nil {
+		return NewScenario(), err
+	}
+	defer file.Close()
+
+	r, err := utils.Decompress(file)
+	if err != nil {
+		return NewScenario(), err
+	}
+
+	reader, err := newCSVFrameReader(r, 0, &MemsFCRData{}, func(row interface{}) *MemsFCRData {
+		return row.(*MemsFCRData)
+	})
+	if err != nil {
+		return NewScenario(), err
+	}
+
+	scenario, err := ReadAll(reader)
+
+	utils.LogI.Printf("loaded scenario %s (%d dataframes)", filepath, scenario.Count)
+
+	return scenario, err
+}
+
+// ConvertFileTo converts the log file directly into the destination file in the format without
+// loading the whole log into memory, returns the number of dataframes converted
+func ConvertFileTo(source string, destination string, format string) (int, error) {
+	file, err := os.Open(source)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader, _, err := DetectFrameReader(file)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := streamTo(reader, destination, format)
+	logParseReport(source, reader)
+
+	return count, err
+}
+
+// OutputName returns the name of the converted log, compression extensions are removed
+func OutputName(name string, format string) string {
+	return fmt.Sprintf("%s.output.%s", utils.TrimCompressionExtension(name), format)
+}
+
+// streamTo writes the dataframes into the destination file
+func streamTo(reader FrameReader, destination string, format string) (int, error) {
+	if err := os.MkdirAll(filepath.Dir(destination), os.ModePerm); err != nil {
+		return 0, err
+	}
+
+	output, err := os.Create(destination)
+	if err != nil {
+		return 0, err
+	}
+	defer output.Close()
+
+	writer, err := NewFrameWriter(output, format)
+	if err != nil {
+		return 0, err
+	}
+
+	return Stream(reader, writer)
+}
+
+// logParseReport logs the frames dropped and corrupted parsing the log
+func logParseReport(filepath string, reader FrameReader) {
+	if reporter, ok := reader.(ParseReporter); ok && reporter.Report().HasProblems() {
+		utils.LogW.Printf("problems parsing %s\n%s", filepath, reporter.Report())
+	}
+}
+
+// convertFile converts the log file of the file type for the Convert methods of each log type,
+// which don't return errors so the errors are logged. ConvertFile returns the errors
+func convertFile(filepath string, filetype string) *Scenario {
+	file, err := os.Open(filepath)
+	if err != nil {
+		utils.LogE.Printf("unable to open %s", err)
+		return NewScenario()
+	}
+	defer file.Close()
+
+	reader, err := NewFrameReader(file, filetype)
+	if err != nil {
+		utils.LogE.Printf("unable to parse file %s", err)
+		return NewScenario()
+	}
+
+	scenario, err := ReadAll(reader)
+	if err != nil {
+		utils.LogE.Printf("unable to parse file %s", err)
+	}
+
+	return scenario
+}
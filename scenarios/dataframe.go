@@ -0,0 +1,104 @@
+package scenarios
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+
+	"github.com/andrewdjackson/memscene/utils"
+)
+
+// DecodeDataframes builds the MemsFCRData from the raw 0x80 and 0x7d dataframes, the layout
+// of the dataframes is identified from their length
+func DecodeDataframes(time string, d80 []byte, d7d []byte) *MemsFCRData {
+	data, _ := FindVariant("", d80, d7d).Decode(time, d80, d7d)
+
+	return data
+}
+
+// decodeDataframes decodes dataframes in the standard layout
+func decodeDataframes(time string, d80 []byte, d7d []byte) *MemsFCRData {
+	// populate the DataFrame structure for command 0x80
+	var df80 DataFrame80
+
+	if err := binary.Read(bytes.NewReader(d80), binary.BigEndian, &df80); err != nil {
+		utils.LogE.Printf("%s dataframe x80 binary.Read failed: %v", utils.ECUCommandTrace, err)
+	}
+
+	// populate the DataFrame structure for command 0x7d
+	var df7d DataFrame7d
+
+	if err := binary.Read(bytes.NewReader(d7d), binary.BigEndian, &df7d); err != nil {
+		utils.LogE.Printf("%s dataframe x7d binary.Read failed: %v", utils.ECUCommandTrace, err)
+	}
+
+	// build the Mems Data frame using the raw data and applying the relevant
+	// adjustments and calculations
+	return &MemsFCRData{
+		Time:                     time,
+		EngineRPM:                int(df80.EngineRpm),
+		CoolantTemp:              int(df80.CoolantTemp) - 55,
+		AmbientTemp:              int(df80.AmbientTemp) - 55,
+		IntakeAirTemp:            int(df80.IntakeAirTemp) - 55,
+		FuelTemp:                 int(df80.FuelTemp) - 55,
+		ManifoldAbsolutePressure: float32(df80.ManifoldAbsolutePressure),
+		BatteryVoltage:           float32(df80.BatteryVoltage) / 10,
+		ThrottlePotSensor:        utils.RoundTo2DecimalPoints(float32(df80.ThrottlePotSensor) * 0.02),
+		IdleSwitch:               bool(df80.IdleSwitch&IdleSwitchActive != 0),
+		AirconSwitch:             bool(df80.AirconSwitch != 0),
+		ParkNeutralSwitch:        bool(df80.ParkNeutralSwitch != 0),
+		DTC0:                     df80.Dtc0,
+		DTC1:                     df80.Dtc1,
+		IdleSetPoint:             int(df80.IdleSetPoint),
+		IdleHot:                  int(df80.IdleHot) - 35,
+		Uk8011:                   int(df80.Uk8011),
+		IACPosition:              int(df80.IacPosition),
+		IdleSpeedDeviation:       int(df80.IdleSpeedDeviation),
+		IgnitionAdvanceOffset80:  int(df80.IgnitionAdvanceOffset80),
+		IgnitionAdvance:          (float32(df80.IgnitionAdvance) / 2) - 24,
+		CoilTime:                 utils.RoundTo2DecimalPoints(float32(df80.CoilTime) * 0.002),
+		CrankshaftPositionSensor: bool(df80.CrankshaftPositionSensor != 0),
+		Uk801a:                   int(df80.Uk801a),
+		Uk801b:                   int(df80.Uk801b),
+		IgnitionSwitch:           bool(df7d.IgnitionSwitch != 0),
+		ThrottleAngle:            int(math.Round(float64(df7d.ThrottleAngle) * 6 / 10)),
+		Uk7d03:                   int(df7d.Uk7d03),
+		AirFuelRatio:             float32(df7d.AirFuelRatio) / 10,
+		DTC2:                     df7d.Dtc2,
+		LambdaVoltage:            int(df7d.LambdaVoltage) * 5,
+		LambdaFrequency:          int(df7d.LambdaFrequency),
+		LambdaDutycycle:          int(df7d.LambdaDutyCycle),
+		LambdaStatus:             int(df7d.LambdaStatus),
+		ClosedLoop:               bool(df7d.LoopIndicator != 0),
+		LongTermFuelTrim:         int(df7d.LongTermFuelTrim) - 128,
+		ShortTermFuelTrim:        int(df7d.ShortTermFuelTrim),
+		CarbonCanisterPurgeValve: int(df7d.CarbonCanisterPurgeValve),
+		DTC3:                     df7d.Dtc3,
+		IdleBasePosition:         int(df7d.IdleBasePos),
+		Uk7d10:                   int(df7d.Uk7d10),
+		DTC4:                     df7d.Dtc4,
+		IgnitionAdvanceOffset7d:  int(df7d.IgnitionAdvanceOffset7d) - 48,
+		IdleSpeedOffset:          (int(df7d.IdleSpeedOffset) - 128) * 25,
+		Uk7d14:                   int(df7d.Uk7d14),
+		Uk7d15:                   int(df7d.Uk7d15),
+		DTC5:                     df7d.Dtc5,
+		Uk7d17:                   int(df7d.Uk7d17),
+		Uk7d18:                   int(df7d.Uk7d18),
+		Uk7d19:                   int(df7d.Uk7d19),
+		Uk7d1a:                   int(df7d.Uk7d1a),
+		Uk7d1b:                   int(df7d.Uk7d1b),
+		Uk7d1c:                   int(df7d.Uk7d1c),
+		Uk7d1d:                   int(df7d.Uk7d1d),
+		Uk7d1e:                   int(df7d.Uk7d1e),
+		JackCount:                int(df7d.JackCount),
+		Dataframe80:              hex.EncodeToString(d80),
+		Dataframe7d:              hex.EncodeToString(d7d),
+	}
+}
+
+// setRawDataframes sets the raw dataframes as received from the ECU
+func (data *MemsFCRData) setRawDataframes(d80 []byte, d7d []byte) {
+	data.Dataframe80 = hex.EncodeToString(d80)
+	data.Dataframe7d = hex.EncodeToString(d7d)
+}
@@ -0,0 +1,220 @@
+package scenarios
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// MatchByIndex pairs the dataframes by their position in the scenario
+	MatchByIndex = "index"
+	// MatchByTime pairs the dataframes with the same time
+	MatchByTime = "time"
+)
+
+// FieldDiff summarises the differences in a MemsFCRData field
+type FieldDiff struct {
+	Field string
+	// Frames number of dataframes where the field differs
+	Frames int
+	// MaxDifference largest absolute difference, 0 for non numeric fields
+	MaxDifference float64
+	// MeanDifference mean absolute difference of the frames that differ
+	MeanDifference float64
+}
+
+// ByteDiff summarises the differences in a byte of the raw dataframes
+type ByteDiff struct {
+	// Dataframe 0x80 or 0x7d
+	Dataframe string
+	// Offset of the byte in the dataframe
+	Offset int
+	// Frames number of dataframes where the byte differs
+	Frames int
+}
+
+// ScenarioDiff is the result of comparing two scenarios
+type ScenarioDiff struct {
+	// Match method used to pair the dataframes
+	Match string
+	// Matched number of paired dataframes
+	Matched int
+	// Unmatched number of dataframes in either scenario without a pair
+	Unmatched int
+	// Fields that differ
+	Fields []FieldDiff
+	// Bytes in the raw dataframes that differ
+	Bytes []ByteDiff
+}
+
+// fields that are not compared as values
+var diffExcludedFields = map[string]bool{
+	"Time":        true,
+	"Dataframe80": true,
+	"Dataframe7d": true,
+}
+
+// Diff compares the scenario with another, the dataframes are paired by index or time
+func (scenario *Scenario) Diff(other *Scenario, match string) *ScenarioDiff {
+	diff := &ScenarioDiff{Match: match}
+	pairs := scenario.pairDataframes(other, match)
+
+	diff.Matched = len(pairs)
+	diff.Unmatched = len(scenario.Memsdata) + len(other.Memsdata) - 2*len(pairs)
+
+	fields := make(map[string]*FieldDiff)
+	totals := make(map[string]float64)
+	bytes := make(map[string]*ByteDiff)
+
+	t := reflect.TypeOf(MemsFCRData{})
+
+	for _, pair := range pairs {
+		a := reflect.ValueOf(pair[0]).Elem()
+		b := reflect.ValueOf(pair[1]).Elem()
+
+		for i := 0; i < t.NumField(); i++ {
+			name := t.Field(i).Name
+
+			if diffExcludedFields[name] || !t.Field(i).IsExported() {
+				continue
+			}
+
+			difference, differs := compareValues(a.Field(i), b.Field(i))
+			if !differs {
+				continue
+			}
+
+			f, ok := fields[name]
+			if !ok {
+				f = &FieldDiff{Field: name}
+				fields[name] = f
+			}
+
+			f.Frames++
+			f.MaxDifference = math.Max(f.MaxDifference, difference)
+			totals[name] += difference
+		}
+
+		diffDataframes("0x80", pair[0].Dataframe80, pair[1].Dataframe80, bytes)
+		diffDataframes("0x7d", pair[0].Dataframe7d, pair[1].Dataframe7d, bytes)
+	}
+
+	// report in the order of the MemsFCRData fields
+	for i := 0; i < t.NumField(); i++ {
+		if f, ok := fields[t.Field(i).Name]; ok {
+			f.MeanDifference = totals[f.Field] / float64(f.Frames)
+			diff.Fields = append(diff.Fields, *f)
+		}
+	}
+
+	for _, b := range bytes {
+		diff.Bytes = append(diff.Bytes, *b)
+	}
+
+	sort.Slice(diff.Bytes, func(i, j int) bool {
+		if diff.Bytes[i].Dataframe != diff.Bytes[j].Dataframe {
+			return diff.Bytes[i].Dataframe > diff.Bytes[j].Dataframe
+		}
+
+		return diff.Bytes[i].Offset < diff.Bytes[j].Offset
+	})
+
+	return diff
+}
+
+// pairDataframes pairs the dataframes in the scenarios, when matching by time dataframes
+// with the same time are paired in the order they appear
+func (scenario *Scenario) pairDataframes(other *Scenario, match string) [][2]*MemsFCRData {
+	var pairs [][2]*MemsFCRData
+
+	if match == MatchByTime {
+		times := make(map[string][]*MemsFCRData)
+
+		for _, data := range other.Memsdata {
+			times[data.Time] = append(times[data.Time], data)
+		}
+
+		for _, data := range scenario.Memsdata {
+			if candidates := times[data.Time]; len(candidates) > 0 {
+				pairs = append(pairs, [2]*MemsFCRData{data, candidates[0]})
+				times[data.Time] = candidates[1:]
+			}
+		}
+
+		return pairs
+	}
+
+	for i := 0; i < len(scenario.Memsdata) && i < len(other.Memsdata); i++ {
+		pairs = append(pairs, [2]*MemsFCRData{scenario.Memsdata[i], other.Memsdata[i]})
+	}
+
+	return pairs
+}
+
+// compareValues returns the absolute difference of numeric values and true if the values differ
+func compareValues(a reflect.Value, b reflect.Value) (float64, bool) {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		d := math.Abs(float64(a.Int() - b.Int()))
+		return d, d != 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		d := math.Abs(float64(a.Uint()) - float64(b.Uint()))
+		return d, d != 0
+	case reflect.Float32, reflect.Float64:
+		d := math.Abs(a.Float() - b.Float())
+		return d, d != 0
+	}
+
+	return 0, a.Interface() != b.Interface()
+}
+
+// diffDataframes compares the raw hex dataframes byte by byte, bytes missing
+// from the shorter dataframe count as differences
+func diffDataframes(name string, a string, b string, bytes map[string]*ByteDiff) {
+	da, _ := hex.DecodeString(a)
+	db, _ := hex.DecodeString(b)
+
+	length := len(da)
+	if len(db) > length {
+		length = len(db)
+	}
+
+	for i := 0; i < length; i++ {
+		if i < len(da) && i < len(db) && da[i] == db[i] {
+			continue
+		}
+
+		key := fmt.Sprintf("%s%d", name, i)
+		if _, ok := bytes[key]; !ok {
+			bytes[key] = &ByteDiff{Dataframe: name, Offset: i}
+		}
+
+		bytes[key].Frames++
+	}
+}
+
+// String formats the diff for display
+func (diff *ScenarioDiff) String() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "scenario diff by %s: %d matched, %d unmatched dataframes\n", diff.Match, diff.Matched, diff.Unmatched)
+
+	if len(diff.Fields) == 0 && len(diff.Bytes) == 0 {
+		sb.WriteString("  no differences\n")
+		return sb.String()
+	}
+
+	for _, f := range diff.Fields {
+		fmt.Fprintf(&sb, "  %-26s %6d frames  max %10.3f  mean %10.3f\n", f.Field, f.Frames, f.MaxDifference, f.MeanDifference)
+	}
+
+	for _, b := range diff.Bytes {
+		fmt.Fprintf(&sb, "  %sx%02X %6d frames\n", strings.TrimPrefix(b.Dataframe, "0x"), b.Offset, b.Frames)
+	}
+
+	return sb.String()
+}
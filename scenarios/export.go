@@ -0,0 +1,50 @@
+package scenarios
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// CSVFormat MemsFCR csv file
+	CSVFormat = "csv"
+	// JSONFormat array of MemsFCRData
+	JSONFormat = "json"
+	// NDJSONFormat MemsFCRData a line of JSON per dataframe
+	NDJSONFormat = "ndjson"
+)
+
+// Formats are the formats the scenario can be written in
+var Formats = []string{CSVFormat, JSONFormat, NDJSONFormat}
+
+// Write writes the scenario to the writer in the format
+func (scenario *Scenario) Write(w io.Writer, format string) error {
+	writer, err := NewFrameWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	for _, data := range scenario.Memsdata {
+		if err = writer.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// WriteFile writes the scenario to the file in the format, creating the directory if required
+func (scenario *Scenario) WriteFile(path string, format string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return scenario.Write(file, format)
+}
@@ -0,0 +1,296 @@
+package scenarios
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/andrewdjackson/memscene/utils"
+	"github.com/gocarina/gocsv"
+)
+
+// Injection files are CSV files in the format:
+//
+// fault,channel,start,end,value,probability
+// dtc,coolant,30s,,,
+// open,throttlepot,1m,1m30s,,
+// battery-sag,,,,1.5,
+// lambda-stuck-lean,,2m,,,
+// dropout,map,,,,0.05
+//
+// start and end are the time from the start of the scenario, empty for the whole scenario
+
+const (
+	// DTCFault sets the channel's fault code
+	DTCFault = "dtc"
+	// OpenCircuitFault forces the channel to its open circuit value and sets the fault code
+	OpenCircuitFault = "open"
+	// ShortCircuitFault forces the channel to its short circuit value and sets the fault code
+	ShortCircuitFault = "short"
+	// LambdaStuckLeanFault holds the lambda voltage low
+	LambdaStuckLeanFault = "lambda-stuck-lean"
+	// BatterySagFault drops the battery voltage by the value (V)
+	BatterySagFault = "battery-sag"
+	// DropoutFault intermittently open circuits the channel with the probability
+	DropoutFault = "dropout"
+	// lambdaStuckLeanVoltage raw 7dx06 lambda voltage (100mV)
+	lambdaStuckLeanVoltage = 20
+	// injectionSeed seeds the dropouts so the injected scenarios are repeatable
+	injectionSeed = 1
+)
+
+// Injection is a fault to overlay on a scenario
+type Injection struct {
+	Fault       string  `csv:"fault"`
+	Channel     string  `csv:"channel"`
+	Start       string  `csv:"start"`
+	End         string  `csv:"end"`
+	Value       float64 `csv:"value"`
+	Probability float64 `csv:"probability"`
+	start       time.Duration
+	end         time.Duration
+}
+
+// faultChannel is the position of a sensor in the raw dataframes, the values it reads
+// when open or short circuit and the fault code bit it sets
+type faultChannel struct {
+	dataframe byte
+	position  int
+	open      byte
+	short     byte
+	dtc       int
+	code      byte
+}
+
+// raw dataframe positions include the command byte so 80x03 is at position 0x04
+var faultChannels = map[string]faultChannel{
+	"coolant":     {dataframe: 0x80, position: 0x04, open: 0x00, short: 0xFF, dtc: 0x0E, code: CoolantSensorFaultCode},
+	"airtemp":     {dataframe: 0x80, position: 0x06, open: 0x00, short: 0xFF, dtc: 0x0E, code: AirSensorFaultCode},
+	"map":         {dataframe: 0x80, position: 0x08, open: 0x00, short: 0xFF},
+	"throttlepot": {dataframe: 0x80, position: 0x0A, open: 0x00, short: 0xFF, dtc: 0x0F, code: ThrottlePotFaultCode},
+	"fuelpump":    {dataframe: 0x80, dtc: 0x0F, code: FuelPumpFaultCode},
+	"lambda":      {dataframe: 0x7D, position: 0x07, open: 0x00, short: 0xFF},
+}
+
+const (
+	batteryVoltagePosition80 = 0x09
+	lambdaVoltagePosition7d  = 0x07
+)
+
+// LoadInjections reads the fault injections from the file
+func LoadInjections(filepath string) ([]*Injection, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var injections []*Injection
+
+	if err = gocsv.Unmarshal(file, &injections); err != nil {
+		return nil, err
+	}
+
+	utils.LogI.Printf("loaded %d fault injections from %s", len(injections), filepath)
+
+	return injections, nil
+}
+
+// validate checks the injection and converts the start and end times
+func (injection *Injection) validate() error {
+	var err error
+
+	injection.Fault = strings.ToLower(strings.TrimSpace(injection.Fault))
+	injection.Channel = strings.ToLower(strings.TrimSpace(injection.Channel))
+
+	channel, ok := faultChannels[injection.Channel]
+
+	switch injection.Fault {
+	case DTCFault:
+		if !ok || channel.code == 0 {
+			return fmt.Errorf("no fault code for channel '%s'", injection.Channel)
+		}
+	case OpenCircuitFault, ShortCircuitFault, DropoutFault:
+		if !ok || channel.position == 0 {
+			return fmt.Errorf("unknown sensor channel '%s'", injection.Channel)
+		}
+
+		if injection.Fault == DropoutFault && !(injection.Probability > 0 && injection.Probability <= 1) {
+			return fmt.Errorf("invalid dropout probability %g, expected greater than 0 and at most 1", injection.Probability)
+		}
+	case LambdaStuckLeanFault, BatterySagFault:
+	default:
+		return fmt.Errorf("unknown fault '%s'", injection.Fault)
+	}
+
+	injection.start, injection.end = 0, time.Duration(math.MaxInt64)
+
+	if injection.Start != "" {
+		if injection.start, err = time.ParseDuration(injection.Start); err != nil {
+			return err
+		}
+	}
+
+	if injection.End != "" {
+		if injection.end, err = time.ParseDuration(injection.End); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Inject returns a copy of the scenario with the faults applied to the raw dataframes, only
+// the channels decoded from the bytes changed by the faults are recalculated
+func (scenario *Scenario) Inject(injections []*Injection) (*Scenario, error) {
+	// validate copies so the caller's injections are unchanged
+	var faults []*Injection
+
+	for _, injection := range injections {
+		fault := *injection
+		if err := fault.validate(); err != nil {
+			return nil, err
+		}
+
+		faults = append(faults, &fault)
+	}
+
+	injected := NewScenario()
+	elapsed := scenario.Elapsed()
+	random := rand.New(rand.NewSource(injectionSeed))
+
+	for i, data := range scenario.Memsdata {
+		unchanged := *data
+
+		d80, err80 := hex.DecodeString(data.Dataframe80)
+		d7d, err7d := hex.DecodeString(data.Dataframe7d)
+
+		if err80 != nil || err7d != nil || len(d80) == 0 || len(d7d) == 0 {
+			utils.LogW.Printf("unable to inject faults at %s, no raw dataframes", data.Time)
+			injected.Memsdata = append(injected.Memsdata, &unchanged)
+			continue
+		}
+
+		original80 := append([]byte{}, d80...)
+		original7d := append([]byte{}, d7d...)
+
+		for _, fault := range faults {
+			if elapsed[i] >= fault.start && elapsed[i] <= fault.end {
+				fault.apply(d80, d7d, random)
+			}
+		}
+
+		injected.Memsdata = append(injected.Memsdata, overlay(&unchanged, original80, original7d, d80, d7d))
+	}
+
+	injected.Count = len(injected.Memsdata)
+
+	return injected, nil
+}
+
+// overlay updates the channels of the data whose bytes differ between the original and the
+// faulty dataframes, the other channels keep their values
+func overlay(data *MemsFCRData, original80 []byte, original7d []byte, d80 []byte, d7d []byte) *MemsFCRData {
+	variant := FindVariant("", d80, d7d)
+
+	standard80, standard7d, _ := variant.Frames(original80, original7d)
+	faulty80, faulty7d, _ := variant.Frames(d80, d7d)
+
+	changed := changedChannels(channels80, standard80, faulty80)
+	changed = append(changed, changedChannels(channels7d, standard7d, faulty7d)...)
+
+	if len(changed) == 0 {
+		return data
+	}
+
+	decoded, _ := variant.Decode(data.Time, d80, d7d)
+
+	from := reflect.ValueOf(decoded).Elem()
+	to := reflect.ValueOf(data).Elem()
+
+	for _, name := range changed {
+		if field := to.FieldByName(name); field.IsValid() {
+			field.Set(from.FieldByName(name))
+		}
+	}
+
+	// the unchanged dataframe keeps its original encoding
+	if !bytes.Equal(original80, d80) {
+		data.Dataframe80 = hex.EncodeToString(d80)
+	}
+
+	if !bytes.Equal(original7d, d7d) {
+		data.Dataframe7d = hex.EncodeToString(d7d)
+	}
+
+	return data
+}
+
+// changedChannels returns the channels that have a byte that differs between the frames
+func changedChannels(channels []channelBytes, original []byte, faulty []byte) []string {
+	var changed []string
+
+	for _, channel := range channels {
+		for _, position := range channel.positions {
+			if original[position] != faulty[position] {
+				changed = append(changed, channel.name)
+				break
+			}
+		}
+	}
+
+	return changed
+}
+
+// apply the fault to the raw dataframes
+func (injection *Injection) apply(d80 []byte, d7d []byte, random *rand.Rand) {
+	channel := faultChannels[injection.Channel]
+
+	dataframe := d80
+	if channel.dataframe == 0x7D {
+		dataframe = d7d
+	}
+
+	switch injection.Fault {
+	case DTCFault:
+		setFaultCode(d80, channel)
+	case OpenCircuitFault:
+		setRawByte(dataframe, channel.position, channel.open)
+		setFaultCode(d80, channel)
+	case ShortCircuitFault:
+		setRawByte(dataframe, channel.position, channel.short)
+		setFaultCode(d80, channel)
+	case DropoutFault:
+		if random.Float64() < injection.Probability {
+			setRawByte(dataframe, channel.position, channel.open)
+		}
+	case LambdaStuckLeanFault:
+		setRawByte(d7d, lambdaVoltagePosition7d, lambdaStuckLeanVoltage)
+	case BatterySagFault:
+		if batteryVoltagePosition80 < len(d80) {
+			voltage := int(d80[batteryVoltagePosition80]) - int(injection.Value*10)
+			if voltage < 0 {
+				voltage = 0
+			}
+			d80[batteryVoltagePosition80] = byte(voltage)
+		}
+	}
+}
+
+func setFaultCode(d80 []byte, channel faultChannel) {
+	if channel.code != 0 && channel.dtc < len(d80) {
+		d80[channel.dtc] |= channel.code
+	}
+}
+
+func setRawByte(dataframe []byte, position int, value byte) {
+	if position < len(dataframe) {
+		dataframe[position] = value
+	}
+}
@@ -0,0 +1,82 @@
+package scenarios
+
+// MEMS 1.9 and MEMS 2J ECUs use KWP2000 (ISO 14230) messages. The live data is read with the
+// readDataByLocalIdentifier service, 0x21 followed by the local identifier, and the ECU responds
+// with 0x61, the local identifier and the data. A message is
+//
+// format     0x80 plus the length of the data, 0x80 if the length is in a separate byte
+// target     address of the receiver, 0xF1 for the tester
+// source     address of the sender
+// [length]   length of the data if not in the format byte
+// data       service and parameters
+// checksum   sum of the previous bytes
+//
+// The message format is from ISO 14230-2 and the services from ISO 14230-3. The local identifiers
+// and live data layouts of the MEMS 1.9 and MEMS 2J ECUs aren't documented and there are no
+// captures from these ECUs to take them from, so captures carrying KWP2000 responses are
+// recognised and rejected rather than decoded. Readmems and mems-rosco logs from these ECUs
+// aren't recognised.
+
+const (
+	// testerAddress is the address of the diagnostic tester
+	testerAddress = 0xF1
+	// readDataByLocalIdentifierResponse is the positive response to the 0x21 service
+	readDataByLocalIdentifierResponse = 0x61
+)
+
+// kwpMessage is a KWP2000 message
+type kwpMessage struct {
+	target byte
+	source byte
+	data   []byte
+}
+
+// parseKWPMessage parses the message at the start of the buffer, returns the length of the message
+// or the length needed if the buffer is too short, and if the checksum is valid
+func parseKWPMessage(buffer []byte) (*kwpMessage, int, bool) {
+	header := 3
+	length := int(buffer[0] & 0x3F)
+
+	if length == 0 {
+		if len(buffer) < 4 {
+			return nil, 4, false
+		}
+
+		header = 4
+		length = int(buffer[3])
+	}
+
+	size := header + length + 1
+	if len(buffer) < size {
+		return nil, size, false
+	}
+
+	var checksum byte
+	for _, b := range buffer[:size-1] {
+		checksum += b
+	}
+
+	message := &kwpMessage{target: buffer[1], source: buffer[2], data: buffer[header : size-1]}
+
+	return message, size, checksum == buffer[size-1]
+}
+
+// isResponseHeader returns true if the bytes are the start of a message to the tester
+func isResponseHeader(buffer []byte) bool {
+	return len(buffer) >= 3 && buffer[0]&0xC0 == 0x80 && buffer[1] == testerAddress
+}
+
+// findKWPResponse returns the address of the ECU that sent a valid live data response in the buffer
+func findKWPResponse(buffer []byte) (byte, bool) {
+	for i := range buffer {
+		if !isResponseHeader(buffer[i:]) {
+			continue
+		}
+
+		if message, _, ok := parseKWPMessage(buffer[i:]); ok && len(message.data) > 1 && message.data[0] == readDataByLocalIdentifierResponse {
+			return message.source, true
+		}
+	}
+
+	return 0, false
+}
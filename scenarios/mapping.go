@@ -0,0 +1,82 @@
+package scenarios
+
+// The logs are read into structures matching their format and then mapped into
+// MemsFCRData. The mappings are explicit so fields that differ in type, such as the
+// switches logged as 0 or 1 by mems-rosco, are converted rather than dropped.
+
+// ToMemsFCRData maps the mems-rosco log data into MemsFCRData
+func (data *MemsRoscoData) ToMemsFCRData() *MemsFCRData {
+	return &MemsFCRData{
+		Time:                     data.Time,
+		EngineRPM:                int(data.EngineRPM),
+		CoolantTemp:              data.CoolantTemp,
+		AmbientTemp:              data.AmbientTemp,
+		IntakeAirTemp:            data.IntakeAirTemp,
+		FuelTemp:                 data.FuelTemp,
+		ManifoldAbsolutePressure: data.ManifoldAbsolutePressure,
+		BatteryVoltage:           data.BatteryVoltage,
+		ThrottlePotSensor:        data.ThrottlePotSensor,
+		IdleSwitch:               data.IdleSwitch != 0,
+		AirconSwitch:             data.AirconSwitch != 0,
+		ParkNeutralSwitch:        data.ParkNeutralSwitch != 0,
+		DTC0:                     uint8(data.DTC0),
+		DTC1:                     uint8(data.DTC1),
+		IdleSetPoint:             data.IdleSetPoint,
+		IdleHot:                  data.IdleHot,
+		Uk8011:                   data.Uk8011,
+		IACPosition:              data.IACPosition,
+		IdleSpeedDeviation:       int(data.IdleSpeedDeviation),
+		IgnitionAdvanceOffset80:  data.IgnitionAdvanceOffset80,
+		IgnitionAdvance:          data.IgnitionAdvance,
+		CoilTime:                 data.CoilTime,
+		CrankshaftPositionSensor: data.CrankshaftPositionSensor != 0,
+		Uk801a:                   data.Uk801a,
+		Uk801b:                   data.Uk801b,
+		IgnitionSwitch:           data.IgnitionSwitch != 0,
+		ThrottleAngle:            data.ThrottleAngle,
+		Uk7d03:                   data.Uk7d03,
+		AirFuelRatio:             data.AirFuelRatio,
+		DTC2:                     uint8(data.DTC2),
+		LambdaVoltage:            data.LambdaVoltage,
+		LambdaFrequency:          data.LambdaFrequency,
+		LambdaDutycycle:          data.LambdaDutycycle,
+		LambdaStatus:             data.LambdaStatus,
+		ClosedLoop:               data.ClosedLoop != 0,
+		LongTermFuelTrim:         data.LongTermFuelTrim,
+		ShortTermFuelTrim:        data.ShortTermFuelTrim,
+		CarbonCanisterPurgeValve: data.CarbonCanisterPurgeValve,
+		DTC3:                     uint8(data.DTC3),
+		IdleBasePosition:         data.IdleBasePosition,
+		Uk7d10:                   data.Uk7d10,
+		DTC4:                     uint8(data.DTC4),
+		IgnitionAdvanceOffset7d:  data.IgnitionAdvanceOffset7d,
+		IdleSpeedOffset:          data.IdleSpeedOffset,
+		Uk7d14:                   data.Uk7d14,
+		Uk7d15:                   data.Uk7d15,
+		DTC5:                     uint8(data.DTC5),
+		Uk7d17:                   data.Uk7d17,
+		Uk7d18:                   data.Uk7d18,
+		Uk7d19:                   data.Uk7d19,
+		Uk7d1a:                   data.Uk7d1a,
+		Uk7d1b:                   data.Uk7d1b,
+		Uk7d1c:                   data.Uk7d1c,
+		Uk7d1d:                   data.Uk7d1d,
+		Uk7d1e:                   data.Uk7d1e,
+		JackCount:                data.JackCount,
+		Dataframe7d:              data.Dataframe7d,
+		Dataframe80:              data.Dataframe80,
+	}
+}
+
+// ToMemsFCRData maps the mems-rosco version 2 log data into MemsFCRData, the fields
+// are the same as the version 1 log, only the CSV headers differ
+func (data *MemsRoscoV2Data) ToMemsFCRData() *MemsFCRData {
+	v1 := MemsRoscoData(*data)
+	return v1.ToMemsFCRData()
+}
+
+// ToMemsFCRData maps the reprocessed MemsFCR data into MemsFCRData
+func (data *MemsFCRRawData) ToMemsFCRData() *MemsFCRData {
+	memsdata := MemsFCRData(*data)
+	return &memsdata
+}
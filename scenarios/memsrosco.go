@@ -1,21 +1,16 @@
 package scenarios
 
 import (
-	"encoding/json"
 	"fmt"
 	"math"
-	"os"
 	"strings"
 
 	"github.com/andrewdjackson/memscene/utils"
-	"github.com/gocarina/gocsv"
 )
 
 // MemsRosco structure
 type MemsRosco struct {
 	scenario *Scenario
-	file     *os.File
-	data     []*MemsRoscoData
 }
 
 // NewMemsRosco create a new MemsRosco instance
@@ -28,28 +23,17 @@ func NewMemsRosco() *MemsRosco {
 
 // Convert takes Readmems Log files and converts them into MemsFCR format
 func (memsrosco *MemsRosco) Convert(filepath string) *Scenario {
-	// open the file
-	memsrosco.file = utils.OpenFile(filepath)
+	memsrosco.scenario = convertFile(filepath, utils.MemsRoscoFile)
 
-	// marshall into the correct format
-	roscofile, _ := utils.NewLineSkipDecoder(memsrosco.file, 1)
-
-	if err := gocsv.UnmarshalDecoder(roscofile, &memsrosco.data); err != nil {
-		utils.LogE.Printf("unable to parse file %s", err)
-	} else {
-		memsrosco.scenario.Count = len(memsrosco.data)
-		utils.LogI.Printf("loaded scenario %s (%d dataframes)", filepath, memsrosco.scenario.Count)
-
-		// recreate the Dataframes from the CSV values
-		for _, m := range memsrosco.data {
-			memsrosco.recreateDataframes(m)
-		}
-	}
+	return memsrosco.scenario
+}
 
-	i, _ := json.Marshal(memsrosco.data)
-	_ = json.Unmarshal(i, &memsrosco.scenario.Memsdata)
+// frame recreates the dataframes for a row of the log
+func (memsrosco *MemsRosco) frame(row interface{}) *MemsFCRData {
+	data := row.(*MemsRoscoData)
+	memsrosco.recreateDataframes(data)
 
-	return memsrosco.scenario
+	return data.ToMemsFCRData()
 }
 
 // Recreate the Dataframe HEX data from the parameters
@@ -1,21 +1,16 @@
 package scenarios
 
 import (
-	"encoding/json"
 	"fmt"
 	"math"
-	"os"
 	"strings"
 
 	"github.com/andrewdjackson/memscene/utils"
-	"github.com/gocarina/gocsv"
 )
 
 // MemsRoscoV2 structure
 type MemsRoscoV2 struct {
 	scenario *Scenario
-	file     *os.File
-	data     []*MemsRoscoV2Data
 }
 
 // NewMemsRoscoV2 create a new MemsRosco instance
@@ -28,28 +23,17 @@ func NewMemsRoscoV2() *MemsRoscoV2 {
 
 // Convert takes Readmems Log files and converts them into MemsFCR format
 func (memsrosco *MemsRoscoV2) Convert(filepath string) *Scenario {
-	// open the file
-	memsrosco.file = utils.OpenFile(filepath)
+	memsrosco.scenario = convertFile(filepath, utils.MemsRoscoFilev2)
 
-	// marshall into the correct format
-	roscofile, _ := utils.NewLineSkipDecoder(memsrosco.file, 1)
-
-	if err := gocsv.UnmarshalDecoder(roscofile, &memsrosco.data); err != nil {
-		utils.LogE.Printf("unable to parse file %s", err)
-	} else {
-		memsrosco.scenario.Count = len(memsrosco.data)
-		utils.LogI.Printf("loaded scenario %s (%d dataframes)", filepath, memsrosco.scenario.Count)
-
-		// recreate the Dataframes from the CSV values
-		for _, m := range memsrosco.data {
-			memsrosco.recreateDataframes(m)
-		}
-	}
+	return memsrosco.scenario
+}
 
-	i, _ := json.Marshal(memsrosco.data)
-	_ = json.Unmarshal(i, &memsrosco.scenario.Memsdata)
+// frame recreates the dataframes for a row of the log
+func (memsrosco *MemsRoscoV2) frame(row interface{}) *MemsFCRData {
+	data := row.(*MemsRoscoV2Data)
+	memsrosco.recreateDataframes(data)
 
-	return memsrosco.scenario
+	return data.ToMemsFCRData()
 }
 
 // Recreate the Dataframe HEX data from the parameters
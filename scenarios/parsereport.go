@@ -0,0 +1,72 @@
+package scenarios
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maximumProblems limits the number of problems kept in the report
+const maximumProblems = 100
+
+// ParseReport describes the problems found parsing a log
+type ParseReport struct {
+	// Frames is the number of dataframes built from paired 0x80 and 0x7d frames
+	Frames int
+	// Dropped is the number of frames discarded without a matching pair
+	Dropped int
+	// Corrupted is the number of frames that were truncated or the wrong length
+	Corrupted int
+	// Partial is the number of dataframes decoded from short frames with channels missing
+	Partial int
+	// Skipped is the amount of data skipped resynchronising, bytes or lines depending on the log
+	Skipped int
+	// Problems describes where the frames were dropped or corrupted
+	Problems []string
+}
+
+// ParseReporter is implemented by the frame readers that report the problems found parsing the log
+type ParseReporter interface {
+	// Report returns the report for the log read so far
+	Report() *ParseReport
+}
+
+// HasProblems returns true if any frames were dropped or corrupted
+func (report *ParseReport) HasProblems() bool {
+	return report.Dropped > 0 || report.Corrupted > 0
+}
+
+// decoded records a dataframe, the dataframe is partial if channels are missing
+func (report *ParseReport) decoded(missing []string) {
+	report.Frames++
+
+	if len(missing) > 0 {
+		report.Partial++
+	}
+}
+
+// problem records a dropped or corrupted frame
+func (report *ParseReport) problem(format string, args ...interface{}) {
+	if len(report.Problems) < maximumProblems {
+		report.Problems = append(report.Problems, fmt.Sprintf(format, args...))
+	}
+}
+
+func (report *ParseReport) String() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "parse report (%d dataframes)\n", report.Frames)
+	fmt.Fprintf(&sb, "  dropped frames:   %d\n", report.Dropped)
+	fmt.Fprintf(&sb, "  corrupted frames: %d\n", report.Corrupted)
+	fmt.Fprintf(&sb, "  partial frames:   %d\n", report.Partial)
+	fmt.Fprintf(&sb, "  skipped:          %d\n", report.Skipped)
+
+	for _, problem := range report.Problems {
+		fmt.Fprintf(&sb, "  %s\n", problem)
+	}
+
+	if len(report.Problems) == maximumProblems {
+		fmt.Fprintf(&sb, "  ...\n")
+	}
+
+	return sb.String()
+}
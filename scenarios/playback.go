@@ -0,0 +1,257 @@
+package scenarios
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// The playback cursor steps through the scenario. Position is the index of the
+// dataframe that Next will return, the current dataframe is the one most recently
+// returned by Next or Prev. All the playback methods are safe for concurrent use.
+
+// SetLoop sets whether playback restarts from the beginning at the end of the scenario
+func (scenario *Scenario) SetLoop(loop bool) {
+	scenario.mutex.Lock()
+	defer scenario.mutex.Unlock()
+
+	scenario.loop = loop
+}
+
+// SetSpeed sets the playback speed, 1 is real time, 2 twice as fast. The speed
+// must be greater than 0
+func (scenario *Scenario) SetSpeed(speed float64) error {
+	if speed <= 0 || math.IsNaN(speed) || math.IsInf(speed, 0) {
+		return fmt.Errorf("invalid playback speed %g, the speed must be greater than 0", speed)
+	}
+
+	scenario.mutex.Lock()
+	defer scenario.mutex.Unlock()
+
+	scenario.speed = speed
+
+	return nil
+}
+
+// Speed returns the playback speed
+func (scenario *Scenario) Speed() float64 {
+	scenario.mutex.RLock()
+	defer scenario.mutex.RUnlock()
+
+	return scenario.speed
+}
+
+// GetPosition returns the playback position
+func (scenario *Scenario) GetPosition() int {
+	scenario.mutex.RLock()
+	defer scenario.mutex.RUnlock()
+
+	return scenario.Position
+}
+
+// Next returns the next dataframe and advances the position, returns false at
+// the end of the scenario unless looping
+func (scenario *Scenario) Next() (*MemsFCRData, bool) {
+	data, _, ok := scenario.next()
+	return data, ok
+}
+
+// next returns the next dataframe and its index, the position is read and advanced
+// under the one lock so a concurrent seek can't change the index
+func (scenario *Scenario) next() (*MemsFCRData, int, bool) {
+	scenario.mutex.Lock()
+	defer scenario.mutex.Unlock()
+
+	if scenario.Position >= len(scenario.Memsdata) {
+		if !scenario.loop || len(scenario.Memsdata) == 0 {
+			return nil, 0, false
+		}
+
+		scenario.Position = 0
+	}
+
+	index := scenario.Position
+	scenario.Position++
+
+	return scenario.Memsdata[index], index, true
+}
+
+// unread moves the position back to the dataframe at index if it hasn't been
+// moved since the dataframe was returned by next
+func (scenario *Scenario) unread(index int) {
+	scenario.mutex.Lock()
+	defer scenario.mutex.Unlock()
+
+	if scenario.Position == index+1 {
+		scenario.Position = index
+	}
+}
+
+// Prev steps back and returns the dataframe before the current one, returns
+// false at the beginning of the scenario unless looping
+func (scenario *Scenario) Prev() (*MemsFCRData, bool) {
+	scenario.mutex.Lock()
+	defer scenario.mutex.Unlock()
+
+	if scenario.Position < 2 {
+		if !scenario.loop || len(scenario.Memsdata) == 0 {
+			return nil, false
+		}
+
+		// wrap round to the last dataframe
+		scenario.Position = len(scenario.Memsdata) + 1
+	}
+
+	scenario.Position--
+
+	return scenario.Memsdata[scenario.Position-1], true
+}
+
+// Peek returns the next dataframe without moving the position
+func (scenario *Scenario) Peek() (*MemsFCRData, bool) {
+	scenario.mutex.RLock()
+	defer scenario.mutex.RUnlock()
+
+	position := scenario.Position
+
+	if position >= len(scenario.Memsdata) {
+		if !scenario.loop || len(scenario.Memsdata) == 0 {
+			return nil, false
+		}
+
+		position = 0
+	}
+
+	return scenario.Memsdata[position], true
+}
+
+// Reset moves the position back to the beginning of the scenario
+func (scenario *Scenario) Reset() {
+	scenario.mutex.Lock()
+	defer scenario.mutex.Unlock()
+
+	scenario.Position = 0
+}
+
+// SeekIndex moves the position so Next returns the dataframe at the index,
+// returns false if the index is outside the scenario
+func (scenario *Scenario) SeekIndex(index int) bool {
+	scenario.mutex.Lock()
+	defer scenario.mutex.Unlock()
+
+	if index < 0 || index >= len(scenario.Memsdata) {
+		return false
+	}
+
+	scenario.Position = index
+
+	return true
+}
+
+// Seek moves the position so Next returns the first dataframe at or after the time
+// from the start of the scenario, returns false if the time is after the end
+func (scenario *Scenario) Seek(t time.Duration) bool {
+	scenario.mutex.Lock()
+	defer scenario.mutex.Unlock()
+
+	elapsed := scenario.getElapsed()
+	index := sort.Search(len(elapsed), func(i int) bool {
+		return elapsed[i] >= t
+	})
+
+	if index >= len(elapsed) {
+		return false
+	}
+
+	scenario.Position = index
+
+	return true
+}
+
+// Play sends the dataframes from the current position at the timing they were recorded,
+// scaled by the playback speed. The channel is closed at the end of the scenario or
+// when the context is cancelled
+func (scenario *Scenario) Play(ctx context.Context) <-chan *MemsFCRData {
+	frames := make(chan *MemsFCRData)
+
+	go func() {
+		defer close(frames)
+
+		for {
+			data, index, ok := scenario.next()
+			if !ok {
+				return
+			}
+
+			select {
+			case frames <- data:
+			case <-ctx.Done():
+				// the dataframe wasn't sent so play resumes from it
+				scenario.unread(index)
+				return
+			}
+
+			select {
+			case <-time.After(scenario.delay(index)):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return frames
+}
+
+// delay returns the time to wait after the dataframe at index before sending the next
+func (scenario *Scenario) delay(index int) time.Duration {
+	scenario.mutex.Lock()
+	defer scenario.mutex.Unlock()
+
+	elapsed := scenario.getElapsed()
+
+	if index < 0 || index+1 >= len(elapsed) {
+		return 0
+	}
+
+	delay := elapsed[index+1] - elapsed[index]
+	if delay < 0 {
+		return 0
+	}
+
+	if scenario.speed <= 0 {
+		return delay
+	}
+
+	return time.Duration(float64(delay) / scenario.speed)
+}
+
+// elapsedKey identifies the dataframes the elapsed times were calculated from
+type elapsedKey struct {
+	count int
+	first *MemsFCRData
+	last  *MemsFCRData
+	start string
+	end   string
+}
+
+// getElapsed returns the cached elapsed times, recalculated if the dataframes have
+// been replaced, the caller must hold the lock
+func (scenario *Scenario) getElapsed() []time.Duration {
+	key := elapsedKey{count: len(scenario.Memsdata)}
+
+	if key.count > 0 {
+		key.first = scenario.Memsdata[0]
+		key.last = scenario.Memsdata[key.count-1]
+		key.start = key.first.Time
+		key.end = key.last.Time
+	}
+
+	if scenario.elapsed == nil || key != scenario.elapsedKey {
+		scenario.elapsed = scenario.Elapsed()
+		scenario.elapsedKey = key
+	}
+
+	return scenario.elapsed
+}
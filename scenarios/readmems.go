@@ -2,11 +2,8 @@ package scenarios
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/binary"
 	"encoding/hex"
-	"math"
-	"os"
+	"io"
 	"strings"
 	"time"
 
@@ -16,14 +13,16 @@ import (
 // ReadMems logs are piped output from the readmems console and
 // in the format:
 // ECUID:
-// 7D: 00 00 ...
-// 80: 00 00 ...
+// 80: 1C 00 ...
+// 7D: 20 00 ...
 //
+// the log has no timestamps, the time of each dataframe is taken from its 0x80 request
+// in the sequence of requests at the ReadMemsInterval from 00:00:00 so the same log always
+// converts to the same times
 
 // ReadMems structure
 type ReadMems struct {
 	scenario *Scenario
-	memsdata *MemsFCRData
 }
 
 // NewReadMems create a new ReadMems instance
@@ -36,177 +35,186 @@ func NewReadMems() *ReadMems {
 
 // Convert takes Readmems Log file and converts into MemsFCR format
 func (readmems *ReadMems) Convert(filepath string) *Scenario {
-	lines := readmems.readResponseFile(filepath)
-	startTime := time.Now()
-
-	// convert to a compress byte string line by line
-	// from 80: 00 00...
-	// to 800000...
-	// skip header lines
-	for i := 3; i < len(lines); i++ {
-		line := lines[i]
-
-		if readmems.isACommandResponse(line) {
-			line = readmems.cleanCommandResponse(line)
-
-			if strings.HasPrefix(line, "80") {
-				readmems.memsdata = &MemsFCRData{}
-				readmems.memsdata.Dataframe80 = line
-			}
-			if strings.HasPrefix(line, "7D") {
-				readmems.memsdata.Dataframe7d = line
-			}
+	readmems.scenario = convertFile(filepath, utils.ReadMemsFile)
+
+	return readmems.scenario
+}
+
+// ecuIDResponse is the readmems header line with the response to the D0 command
+const ecuIDResponse = "ECU responded to D0 command with:"
+
+// ReadMemsInterval is the assumed time between the 0x80 requests, it isn't recorded in the log.
+// readmems sends the 0x80 and 0x7d requests back to back and at 9600 baud the echoes and
+// responses take about 70ms, set it for logs read at a different rate
+var ReadMemsInterval = 100 * time.Millisecond
+
+// readmems parser states
+const (
+	// awaiting80 waiting for the 0x80 response that starts a dataframe
+	awaiting80 = iota
+	// awaiting7d the 0x80 response has been read, waiting for the following 0x7d response
+	awaiting7d
+)
+
+// readMemsReader parses a readmems log a line at a time, each 0x80 response is paired
+// with the following 0x7d response. Responses to other commands, such as the D0 ECU ID
+// and the fault clear echoes, are skipped without breaking the pairing
+type readMemsReader struct {
+	scanner *bufio.Scanner
+	line    int
+	state   int
+	frame80 []byte
+	line80  int
+	ecuID   string
+	report  *ParseReport
+	// interval between and the number of 0x80 requests
+	interval time.Duration
+	requests int
+	time80   string
+}
+
+func newReadMemsReader(r io.Reader) *readMemsReader {
+	return &readMemsReader{scanner: bufio.NewScanner(r), state: awaiting80, report: &ParseReport{}, interval: ReadMemsInterval}
+}
+
+// Report returns the orphaned and short frames found in the log so far
+func (reader *readMemsReader) Report() *ParseReport {
+	return reader.report
+}
+
+// Read returns the dataframe when a 0x7d response follows a 0x80 response
+func (reader *readMemsReader) Read() (*MemsFCRData, error) {
+	for reader.scanner.Scan() {
+		reader.line++
+
+		line := reader.scanner.Text()
+
+		if strings.HasPrefix(line, ecuIDResponse) {
+			// ECU responded to D0 command with: 99 00 02 03
+			line = "D0:" + strings.TrimPrefix(line, ecuIDResponse)
+		}
 
-			// convert to a struct, response order is 80, 7d so
-			// make sure we don't increment until we have both dataframes
-			if readmems.memsdata.Dataframe80 != "" && readmems.memsdata.Dataframe7d != "" {
-				startTime = startTime.Add(1 * time.Second)
-				readmems.memsdata.Time = startTime.Format("15:04:05")
-				readmems.calculateMemsData(readmems.memsdata)
-				readmems.scenario.Memsdata = append(readmems.scenario.Memsdata, readmems.memsdata)
-				readmems.scenario.Count++
+		frame, ok := parseResponse(line)
+		if !ok {
+			if strings.TrimSpace(line) != "" {
+				reader.report.Skipped++
 			}
+			continue
 		}
-	}
 
-	return readmems.scenario
-}
+		if frame[0] == 0xD0 {
+			// the ECU ID identifies the firmware variant
+			reader.ecuID = hex.EncodeToString(frame[1:])
+		}
 
-// returns true if the line starts with an 80 or 75 which
-// indicate command responses
-func (readmems *ReadMems) isACommandResponse(line string) bool {
-	if strings.HasPrefix(line, "80") {
-		return true
+		if frame[0] != 0x80 && frame[0] != 0x7D {
+			// response to another command
+			reader.report.Skipped++
+			continue
+		}
+
+		// corrupted 0x80 responses keep their place in the sequence of requests
+		var requested string
+		if frame[0] == 0x80 {
+			requested = reader.requestTime()
+		}
+
+		if !reader.isValid(frame) {
+			reader.drop80("is followed by a corrupted frame")
+			continue
+		}
+
+		if frame[0] == 0x80 {
+			reader.drop80("has no 0x7d response")
+			reader.frame80, reader.line80, reader.time80 = frame, reader.line, requested
+			reader.state = awaiting7d
+			continue
+		}
+
+		if reader.state == awaiting80 {
+			reader.report.Dropped++
+			reader.report.problem("line %d: 0x7d response without a 0x80 response", reader.line)
+			continue
+		}
+
+		data, missing := FindVariant(reader.ecuID, reader.frame80, frame).Decode(reader.time80, reader.frame80, frame)
+
+		reader.frame80 = nil
+		reader.state = awaiting80
+		reader.report.decoded(missing)
+
+		return data, nil
 	}
 
-	if strings.HasPrefix(line, "7D") {
-		return true
+	if err := reader.scanner.Err(); err != nil {
+		return nil, err
 	}
 
-	return false
+	reader.drop80("has no 0x7d response at the end of the log")
+
+	return nil, io.EOF
 }
 
-// returns compacted and cleaned response
-func (readmems *ReadMems) cleanCommandResponse(line string) string {
-	// remove the : character
-	line = strings.ReplaceAll(line, ":", "")
-	// remove all the spaces
-	line = strings.ReplaceAll(line, " ", "")
-	// remove all the LF
-	line = strings.ReplaceAll(line, "\n", "")
-	line = strings.ReplaceAll(line, "\r", "")
-
-	return line
+// requestTime returns the time of the next 0x80 request
+func (reader *readMemsReader) requestTime() string {
+	t := time.Time{}.Add(time.Duration(reader.requests) * reader.interval)
+	reader.requests++
+
+	return formatTime(t)
 }
 
-// ReadMems logs are piped output from the readmems console and
-// in the format:
-// 7D: 00 00 ...
-// 80: 00 00 ...
-// this function reads the response file into an array of strings for
-// processing
-func (readmems *ReadMems) readResponseFile(path string) []string {
-	file, err := os.Open(path)
-
-	if err != nil {
-		utils.LogE.Printf("unable to open %s", err)
+// isValid checks the frame is the length given by BytesinFrame, short frames from firmware
+// variants are valid and decoded with the missing channels left empty
+func (reader *readMemsReader) isValid(frame []byte) bool {
+	if len(frame) < 2 {
+		reader.report.Corrupted++
+		reader.report.problem("line %d: 0x%02X frame is empty", reader.line, frame[0])
+		return false
 	}
-	defer file.Close()
 
-	var lines []string
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	if int(frame[1])+1 != len(frame) {
+		reader.report.Corrupted++
+		reader.report.problem("line %d: 0x%02X frame is %d bytes, expected %d", reader.line, frame[0], len(frame), int(frame[1])+1)
+		return false
 	}
 
-	return lines
+	return true
 }
 
-// calculateMemsData reads the raw dataframes and returns structured data
-func (readmems *ReadMems) calculateMemsData(memsdata *MemsFCRData) {
-	d80, _ := hex.DecodeString(memsdata.Dataframe80)
-	d7d, _ := hex.DecodeString(memsdata.Dataframe7d)
+// drop80 reports the pending 0x80 response as dropped
+func (reader *readMemsReader) drop80(reason string) {
+	if reader.state == awaiting7d {
+		reader.report.Dropped++
+		reader.report.problem("line %d: 0x80 response %s", reader.line80, reason)
+	}
 
-	utils.LogI.Printf("%s getting x7d and x80 dataframes", utils.ECUCommandTrace)
+	reader.frame80 = nil
+	reader.state = awaiting80
+}
 
-	// populate the DataFrame structure for command 0x80
-	r := bytes.NewReader(d80)
-	var df80 DataFrame80
+// parseResponse parses a command response line in the format 80: 1C 00 ..., returns the frame
+// starting with the command. The frame ends at the first byte that isn't hex
+func parseResponse(line string) ([]byte, bool) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
 
-	if err := binary.Read(r, binary.BigEndian, &df80); err != nil {
-		utils.LogE.Printf("%s dataframe x80 binary.Read failed: %v", utils.ECUCommandTrace, err)
+	command, err := hex.DecodeString(strings.TrimSpace(parts[0]))
+	if err != nil || len(command) != 1 {
+		return nil, false
 	}
 
-	// populate the DataFrame structure for command 0x7d
-	r = bytes.NewReader(d7d)
-	var df7d DataFrame7d
+	frame := command
 
-	if err := binary.Read(r, binary.BigEndian, &df7d); err != nil {
-		utils.LogE.Printf("%s dataframe x7d binary.Read failed: %v", utils.ECUCommandTrace, err)
-	}
+	for _, field := range strings.Fields(parts[1]) {
+		b, err := hex.DecodeString(field)
+		if err != nil || len(b) != 1 {
+			break
+		}
 
-	t := time.Now()
-
-	// calculate IAC postion, 0 closed - 180 fully open
-	// convert to %
-	//iac := math.Round(float64(df80.IacPosition) / 1.8)
-	//if iac > 100 {
-	// if value is > 100% then cap it
-	//	iac = 100
-	//}
-
-	// build the Mems Data frame using the raw data and applying the relevant
-	// adjustments and calculations
-	readmems.memsdata = &MemsFCRData{
-		Time:                     t.Format("15:04:05.000"),
-		EngineRPM:                int(df80.EngineRpm),
-		CoolantTemp:              int(df80.CoolantTemp) - 55,
-		AmbientTemp:              int(df80.AmbientTemp) - 55,
-		IntakeAirTemp:            int(df80.IntakeAirTemp) - 55,
-		FuelTemp:                 int(df80.FuelTemp) - 55,
-		ManifoldAbsolutePressure: float32(df80.ManifoldAbsolutePressure),
-		BatteryVoltage:           float32(df80.BatteryVoltage) / 10,
-		ThrottlePotSensor:        roundTo2DecimalPoints(float32(df80.ThrottlePotSensor) * 0.02),
-		IdleSwitch:               bool(df80.IdleSwitch&IdleSwitchActive != 0),
-		AirconSwitch:             bool(df80.AirconSwitch != 0),
-		ParkNeutralSwitch:        bool(df80.ParkNeutralSwitch != 0),
-		DTC0:                     df80.Dtc0,
-		DTC1:                     df80.Dtc1,
-		IdleSetPoint:             int(df80.IdleSetPoint),
-		IdleHot:                  int(df80.IdleHot) - 35,
-		IACPosition:              int(df80.IacPosition),
-		IdleSpeedDeviation:       int(df80.IdleSpeedDeviation),
-		IgnitionAdvanceOffset80:  int(df80.IgnitionAdvanceOffset80),
-		IgnitionAdvance:          (float32(df80.IgnitionAdvance) / 2) - 24,
-		CoilTime:                 roundTo2DecimalPoints(float32(df80.CoilTime) * 0.002),
-		CrankshaftPositionSensor: bool(df80.CrankshaftPositionSensor != 0),
-		IgnitionSwitch:           bool(df7d.IgnitionSwitch != 0),
-		ThrottleAngle:            int(math.Round(float64(df7d.ThrottleAngle) * 6 / 10)),
-		AirFuelRatio:             float32(df7d.AirFuelRatio) / 10,
-		DTC2:                     df7d.Dtc2,
-		LambdaVoltage:            int(df7d.LambdaVoltage) * 5,
-		LambdaFrequency:          int(df7d.LambdaFrequency),
-		LambdaDutycycle:          int(df7d.LambdaDutyCycle),
-		LambdaStatus:             int(df7d.LambdaStatus),
-		ClosedLoop:               bool(df7d.LoopIndicator != 0),
-		LongTermFuelTrim:         int(df7d.LongTermFuelTrim) - 128,
-		ShortTermFuelTrim:        int(df7d.ShortTermFuelTrim),
-		CarbonCanisterPurgeValve: int(df7d.CarbonCanisterPurgeValve),
-		DTC3:                     df7d.Dtc3,
-		IdleBasePosition:         int(df7d.IdleBasePos),
-		DTC4:                     df7d.Dtc4,
-		IgnitionAdvanceOffset7d:  int(df7d.IgnitionAdvanceOffset7d) - 48,
-		IdleSpeedOffset:          int(df7d.IdleSpeedOffset), // - 128) * 25,
-		DTC5:                     df7d.Dtc5,
-		JackCount:                int(df7d.JackCount),
-		Dataframe80:              hex.EncodeToString(d80),
-		Dataframe7d:              hex.EncodeToString(d7d),
+		frame = append(frame, b[0])
 	}
 
-	utils.LogI.Printf("%s built mems dataframe %v", utils.ECUCommandTrace, readmems.memsdata)
-}
-
-func roundTo2DecimalPoints(x float32) float32 {
-	return float32(math.Round(float64(x)*100) / 100)
+	return frame, true
 }
@@ -1,10 +1,10 @@
 package scenarios
 
 import (
-	"os"
+	"sync"
+	"time"
 
 	"github.com/andrewdjackson/memscene/utils"
-	"github.com/gocarina/gocsv"
 )
 
 // Scenario represents the scenario data
@@ -15,6 +15,12 @@ type Scenario struct {
 	Position int
 	// Count of items in the log
 	Count int
+	// playback state
+	mutex      sync.RWMutex
+	loop       bool
+	speed      float64
+	elapsed    []time.Duration
+	elapsedKey elapsedKey
 }
 
 // NewScenario creates a new scenario
@@ -26,16 +32,43 @@ func NewScenario() *Scenario {
 	scenario.Position = 0
 	// no items in the log
 	scenario.Count = 0
+	// play back in real time
+	scenario.speed = 1
 
 	return scenario
 }
 
 // SaveCSVFile saves the Memdata to a CSV file
 func (scenario *Scenario) SaveCSVFile(filepath string) {
-	file, _ := os.OpenFile(filepath, os.O_RDWR|os.O_CREATE, os.ModePerm)
-
-	err := gocsv.MarshalFile(&scenario.Memsdata, file)
-	if err != nil {
+	if err := scenario.WriteFile(filepath, CSVFormat); err != nil {
 		utils.LogI.Printf("error saving csv file %s", err)
 	}
 }
+
+// Elapsed returns the time of each dataframe relative to the start of the scenario.
+// Dataframes without a valid time are assumed to be 1 second after the previous dataframe
+func (scenario *Scenario) Elapsed() []time.Duration {
+	elapsed := make([]time.Duration, len(scenario.Memsdata))
+
+	var start, previous time.Duration
+
+	for i, data := range scenario.Memsdata {
+		t, err := utils.TimestampToDuration(data.Time)
+
+		if err != nil {
+			t = previous + time.Second
+		} else if i > 0 && t < previous-12*time.Hour {
+			// the log has run past midnight
+			t += 24 * time.Hour
+		}
+
+		if i == 0 {
+			start = t
+		}
+
+		elapsed[i] = t - start
+		previous = t
+	}
+
+	return elapsed
+}
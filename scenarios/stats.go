@@ -0,0 +1,135 @@
+package scenarios
+
+import (
+	"math"
+	"reflect"
+	"time"
+)
+
+// ChannelStats summarises a numeric channel over the scenario
+type ChannelStats struct {
+	Channel string
+	Unit    string
+	Min     float64
+	Max     float64
+	Mean    float64
+}
+
+// Stats summarises the scenario
+type Stats struct {
+	Count    int
+	Start    string
+	End      string
+	Duration time.Duration
+	Channels []ChannelStats
+}
+
+// Fault is a fault code reported in the scenario
+type Fault struct {
+	Fault string
+	First string
+	Last  string
+	Count int
+}
+
+// faultCodes are the fault code bits in DTC0 and DTC1
+var faultCodes = []struct {
+	name string
+	dtc  func(data *MemsFCRData) uint8
+	code byte
+}{
+	{"coolant sensor", func(data *MemsFCRData) uint8 { return data.DTC0 }, CoolantSensorFaultCode},
+	{"air sensor", func(data *MemsFCRData) uint8 { return data.DTC0 }, AirSensorFaultCode},
+	{"fuel pump", func(data *MemsFCRData) uint8 { return data.DTC1 }, FuelPumpFaultCode},
+	{"throttle pot", func(data *MemsFCRData) uint8 { return data.DTC1 }, ThrottlePotFaultCode},
+}
+
+// Stats returns the min, max and mean of each numeric channel, values of channels missing from
+// the dataframes are ignored
+func (scenario *Scenario) Stats() *Stats {
+	stats := &Stats{Count: len(scenario.Memsdata)}
+
+	if stats.Count == 0 {
+		return stats
+	}
+
+	elapsed := scenario.Elapsed()
+	stats.Start = scenario.Memsdata[0].Time
+	stats.End = scenario.Memsdata[stats.Count-1].Time
+	stats.Duration = elapsed[stats.Count-1]
+
+	fields := reflect.TypeOf(MemsFCRData{})
+
+	for f := 0; f < fields.NumField(); f++ {
+		channel := ChannelStats{Channel: fields.Field(f).Name, Min: math.Inf(1), Max: math.Inf(-1)}
+		if c := FindChannel(channel.Channel); c != nil {
+			channel.Unit = c.Unit
+		}
+		numeric := true
+		count := 0
+
+		for _, data := range scenario.Memsdata {
+			value, ok := numericValue(reflect.ValueOf(data).Elem().Field(f))
+			if !ok {
+				numeric = false
+				break
+			}
+
+			// channels missing from the dataframe are ignored
+			if !data.HasChannel(channel.Channel) {
+				continue
+			}
+
+			channel.Min = math.Min(channel.Min, value)
+			channel.Max = math.Max(channel.Max, value)
+			channel.Mean += value
+			count++
+		}
+
+		if numeric && count > 0 {
+			channel.Mean /= float64(count)
+			stats.Channels = append(stats.Channels, channel)
+		}
+	}
+
+	return stats
+}
+
+// Faults returns the fault codes reported in the scenario
+func (scenario *Scenario) Faults() []*Fault {
+	var faults []*Fault
+
+	for _, fc := range faultCodes {
+		var fault *Fault
+
+		for _, data := range scenario.Memsdata {
+			if fc.dtc(data)&fc.code == 0 {
+				continue
+			}
+
+			if fault == nil {
+				fault = &Fault{Fault: fc.name, First: data.Time}
+				faults = append(faults, fault)
+			}
+
+			fault.Last = data.Time
+			fault.Count++
+		}
+	}
+
+	return faults
+}
+
+// numericValue returns the value as a float, false if the value isn't a number
+func numericValue(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,282 @@
+package scenarios
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/andrewdjackson/memscene/utils"
+	"github.com/gocarina/gocsv"
+)
+
+// Logs are converted as a stream of dataframes, a FrameReader reads the log one dataframe
+// at a time and a FrameWriter writes each dataframe as it's read, so the memory used
+// doesn't depend on the length of the log.
+
+// FrameReader reads the dataframes from a log
+type FrameReader interface {
+	// Read returns the next dataframe, io.EOF at the end of the log
+	Read() (*MemsFCRData, error)
+}
+
+// FrameWriter writes the dataframes in an output format
+type FrameWriter interface {
+	// Write the dataframe
+	Write(data *MemsFCRData) error
+	// Close completes the output, the underlying writer is not closed
+	Close() error
+}
+
+// NewFrameReader returns a reader for the log file type
+func NewFrameReader(r io.Reader, filetype string) (FrameReader, error) {
+	switch filetype {
+	case utils.ReadMemsFile:
+		return newReadMemsReader(r), nil
+	case utils.MemsRoscoFile:
+		return newCSVFrameReader(r, 1, &MemsRoscoData{}, NewMemsRosco().frame)
+	case utils.MemsRoscoFilev2:
+		return newCSVFrameReader(r, 1, &MemsRoscoV2Data{}, NewMemsRoscoV2().frame)
+	case utils.MemsFCRFile:
+		return newCSVFrameReader(r, 0, &MemsFCRRawData{}, NewMemsFCR().frame)
+	case utils.SerialCaptureFile:
+		return newCaptureReader(r)
+	case utils.MemsDiagFile:
+		return nil, fmt.Errorf("unable to process memsdiag files, not enough data")
+	}
+
+	return nil, fmt.Errorf("unknown file type")
+}
+
+// detectionPrefix is the amount of the log buffered to detect the file type
+const detectionPrefix = 64 * 1024
+
+// DetectFrameReader identifies the type of the log from a buffered prefix so logs
+// can be read from streams such as stdin, compressed logs are decompressed. Returns
+// the reader and the file type
+func DetectFrameReader(r io.Reader) (FrameReader, string, error) {
+	decompressed, err := utils.Decompress(r)
+	if err != nil {
+		return nil, utils.Unknown, err
+	}
+
+	buffered := bufio.NewReaderSize(decompressed, detectionPrefix)
+
+	prefix, err := buffered.Peek(detectionPrefix)
+	if err != nil && err != io.EOF {
+		return nil, utils.Unknown, err
+	}
+
+	if utils.IsArchiveHeader(prefix) {
+		return nil, utils.ArchiveFile, fmt.Errorf("archives contain multiple logs, convert the archive")
+	}
+
+	filetype := utils.DetectFileType(bytes.NewReader(prefix))
+	utils.LogI.Printf("file identified as '%s' type", filetype)
+
+	reader, err := NewFrameReader(buffered, filetype)
+
+	return reader, filetype, err
+}
+
+// OpenFrameReader opens the log file and identifies its type, the caller closes the file
+func OpenFrameReader(filepath string) (FrameReader, io.Closer, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, _, err := DetectFrameReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return reader, file, nil
+}
+
+// NewFrameWriter returns a writer for the output format
+func NewFrameWriter(w io.Writer, format string) (FrameWriter, error) {
+	switch format {
+	case CSVFormat:
+		return &csvFrameWriter{writer: gocsv.DefaultCSVWriter(w)}, nil
+	case JSONFormat:
+		return &jsonFrameWriter{writer: bufio.NewWriter(w)}, nil
+	case NDJSONFormat:
+		return &ndjsonFrameWriter{writer: bufio.NewWriter(w)}, nil
+	}
+
+	return nil, fmt.Errorf("unknown format '%s'", format)
+}
+
+// Stream writes the dataframes from the reader to the writer, returns the number of dataframes written
+func Stream(reader FrameReader, writer FrameWriter) (int, error) {
+	count := 0
+
+	for {
+		data, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			writer.Close()
+			return count, err
+		}
+
+		if err = writer.Write(data); err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, writer.Close()
+}
+
+// ReadAll reads all the dataframes into a scenario, the dataframes read before an error are returned with the error
+func ReadAll(reader FrameReader) (*Scenario, error) {
+	scenario := NewScenario()
+
+	for {
+		data, err := reader.Read()
+		if err == io.EOF {
+			return scenario, nil
+		}
+
+		if err != nil {
+			return scenario, err
+		}
+
+		scenario.Memsdata = append(scenario.Memsdata, data)
+		scenario.Count++
+	}
+}
+
+// csvFrameReader reads a CSV log a row at a time, frame converts the row into a dataframe
+type csvFrameReader struct {
+	unmarshaller *gocsv.Unmarshaller
+	frame        func(row interface{}) *MemsFCRData
+}
+
+// newCSVFrameReader skips the lines before the CSV header, row is the type of the rows in the CSV file
+func newCSVFrameReader(r io.Reader, linesToSkip int, row interface{}, frame func(row interface{}) *MemsFCRData) (*csvFrameReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	for i := 0; i < linesToSkip; i++ {
+		if _, err := reader.Read(); err != nil {
+			return nil, err
+		}
+	}
+
+	reader.FieldsPerRecord = 0
+
+	unmarshaller, err := gocsv.NewUnmarshaller(reader, row)
+	if err != nil {
+		return nil, err
+	}
+
+	return &csvFrameReader{unmarshaller: unmarshaller, frame: frame}, nil
+}
+
+func (reader *csvFrameReader) Read() (*MemsFCRData, error) {
+	row, err := reader.unmarshaller.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	return reader.frame(row), nil
+}
+
+// csvFrameWriter writes the dataframes in the MemsFCR CSV format
+type csvFrameWriter struct {
+	writer  *gocsv.SafeCSVWriter
+	started bool
+}
+
+func (writer *csvFrameWriter) Write(data *MemsFCRData) error {
+	if err := writer.writeHeader(); err != nil {
+		return err
+	}
+
+	return gocsv.MarshalCSVWithoutHeaders(&[]*MemsFCRData{data}, writer.writer)
+}
+
+func (writer *csvFrameWriter) Close() error {
+	// write the header for an empty log
+	if err := writer.writeHeader(); err != nil {
+		return err
+	}
+
+	writer.writer.Flush()
+	return writer.writer.Error()
+}
+
+// writeHeader writes the channel columns before the first dataframe
+func (writer *csvFrameWriter) writeHeader() error {
+	if writer.started {
+		return nil
+	}
+
+	writer.started = true
+
+	return writer.writer.Write(Columns())
+}
+
+// jsonFrameWriter writes the dataframes as a JSON array
+type jsonFrameWriter struct {
+	writer  *bufio.Writer
+	started bool
+}
+
+func (writer *jsonFrameWriter) Write(data *MemsFCRData) error {
+	separator := ","
+	if !writer.started {
+		writer.started = true
+		separator = "["
+	}
+
+	frame, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	writer.writer.WriteString(separator)
+	_, err = writer.writer.Write(frame)
+
+	return err
+}
+
+func (writer *jsonFrameWriter) Close() error {
+	if !writer.started {
+		writer.writer.WriteString("[")
+	}
+
+	writer.writer.WriteString("]\n")
+
+	return writer.writer.Flush()
+}
+
+// ndjsonFrameWriter writes each dataframe as a line of JSON
+type ndjsonFrameWriter struct {
+	writer *bufio.Writer
+}
+
+func (writer *ndjsonFrameWriter) Write(data *MemsFCRData) error {
+	frame, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	writer.writer.Write(frame)
+
+	return writer.writer.WriteByte('\n')
+}
+
+func (writer *ndjsonFrameWriter) Close() error {
+	return writer.writer.Flush()
+}
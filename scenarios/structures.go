@@ -60,6 +60,8 @@ type MemsFCRData struct {
 	JackCount                int     `csv:"7dx1F_uk19"`
 	Dataframe7d              string  `csv:"0x7d_raw"`
 	Dataframe80              string  `csv:"0x80_raw"`
+	// missing are the channels whose bytes weren't in the dataframes
+	missing []string
 }
 
 // MemsFCRRawData structure used for reprocessing raw data
@@ -122,6 +124,8 @@ type MemsFCRRawData struct {
 	JackCount                int     `csv:"-"`
 	Dataframe7d              string  `csv:"0x7d_raw"`
 	Dataframe80              string  `csv:"0x80_raw"`
+	// missing are the channels whose bytes weren't in the dataframes
+	missing []string
 }
 
 // MemsRoscoData mems-rosco logs are in CSV format with a .TXT extension in the format:
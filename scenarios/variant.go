@@ -0,0 +1,285 @@
+package scenarios
+
+import (
+	"encoding/binary"
+	"reflect"
+	"sync"
+)
+
+// ECU firmware variants return dataframes of different lengths and some move or reuse bytes.
+// The variant is identified from the ECU ID, the response to the D0 command, and the
+// BytesinFrame of the frames. The frames are rearranged into the standard layout of
+// DataFrame80 and DataFrame7d before decoding, the channels whose bytes are missing from
+// short frames are recorded as missing on the dataframe rather than decoded from padding.
+
+// Variant is the layout of the dataframes returned by an ECU firmware
+type Variant struct {
+	// Name of the firmware
+	Name string
+	// ECUIDs are the hex encoded responses to the D0 command, any ECU if empty
+	ECUIDs []string
+	// Length80 is the BytesinFrame of the 0x80 frame, any length if 0
+	Length80 byte
+	// Length7d is the BytesinFrame of the 0x7d frame, any length if 0
+	Length7d byte
+	// Layout80 maps the position of a byte in the 0x80 frame to the position in DataFrame80,
+	// positions not in the layout are in the standard position. A position of -1 drops the byte
+	Layout80 map[int]int
+	// Layout7d maps the position of a byte in the 0x7d frame to the position in DataFrame7d
+	Layout7d map[int]int
+}
+
+// StandardVariant is the MEMS 1.6 layout, frames shorter than the standard layout are
+// decoded with the trailing channels missing
+var StandardVariant = &Variant{Name: "MEMS 1.6"}
+
+// ReadmemsVariant is the firmware of the ECU in data/readmems.data, the ECU responds to the D0
+// command with 99 00 02 03 and returns the standard 0x80 and 0x7d frames
+var ReadmemsVariant = &Variant{
+	Name:     "MEMS 1.6 99 00 02 03",
+	ECUIDs:   []string{"99000203"},
+	Length80: 0x1C,
+	Length7d: 0x20,
+}
+
+var (
+	variantsMutex sync.RWMutex
+	variants      = []*Variant{ReadmemsVariant}
+)
+
+var (
+	size80 = binary.Size(DataFrame80{})
+	size7d = binary.Size(DataFrame7d{})
+	// channels80 and channels7d are the positions of the bytes each channel is decoded from
+	channels80 = channelPositions(DataFrame80{})
+	channels7d = channelPositions(DataFrame7d{})
+)
+
+// channelNames maps the DataFrame fields to the MemsFCRData fields where the names differ
+var channelNames = map[string]string{
+	"EngineRpm":       "EngineRPM",
+	"Dtc0":            "DTC0",
+	"Dtc1":            "DTC1",
+	"Dtc2":            "DTC2",
+	"Dtc3":            "DTC3",
+	"Dtc4":            "DTC4",
+	"Dtc5":            "DTC5",
+	"IacPosition":     "IACPosition",
+	"LambdaDutyCycle": "LambdaDutycycle",
+	"LoopIndicator":   "ClosedLoop",
+	"IdleBasePos":     "IdleBasePosition",
+}
+
+// RegisterVariant adds the variant, variants registered later take priority
+func RegisterVariant(variant *Variant) {
+	variantsMutex.Lock()
+	defer variantsMutex.Unlock()
+
+	variants = append([]*Variant{variant}, variants...)
+}
+
+// UnregisterVariant removes the variant
+func UnregisterVariant(variant *Variant) {
+	variantsMutex.Lock()
+	defer variantsMutex.Unlock()
+
+	for i, v := range variants {
+		if v == variant {
+			variants = append(variants[:i:i], variants[i+1:]...)
+			return
+		}
+	}
+}
+
+// FindVariant returns the variant for the ECU ID and the frames, the standard variant if none match
+func FindVariant(ecuID string, d80 []byte, d7d []byte) *Variant {
+	variantsMutex.RLock()
+	defer variantsMutex.RUnlock()
+
+	for _, variant := range variants {
+		if variant.matches(ecuID, d80, d7d) {
+			return variant
+		}
+	}
+
+	return StandardVariant
+}
+
+// frameLengths returns the BytesinFrame of the 0x80 and 0x7d frames returned by the variants
+func frameLengths() ([]byte, []byte) {
+	variantsMutex.RLock()
+	defer variantsMutex.RUnlock()
+
+	lengths80 := []byte{byte(size80 - 1)}
+	lengths7d := []byte{byte(size7d - 1)}
+
+	for _, variant := range variants {
+		if variant.Length80 != 0 {
+			lengths80 = append(lengths80, variant.Length80)
+		}
+
+		if variant.Length7d != 0 {
+			lengths7d = append(lengths7d, variant.Length7d)
+		}
+	}
+
+	return lengths80, lengths7d
+}
+
+func (variant *Variant) matches(ecuID string, d80 []byte, d7d []byte) bool {
+	if len(variant.ECUIDs) > 0 && !contains(variant.ECUIDs, ecuID) {
+		return false
+	}
+
+	if variant.Length80 != 0 && (len(d80) < 2 || d80[1] != variant.Length80) {
+		return false
+	}
+
+	if variant.Length7d != 0 && (len(d7d) < 2 || d7d[1] != variant.Length7d) {
+		return false
+	}
+
+	return true
+}
+
+// Decode builds the MemsFCRData from the 0x80 and 0x7d frames, returns the names of the channels
+// that are missing from the frames
+func (variant *Variant) Decode(time string, d80 []byte, d7d []byte) (*MemsFCRData, []string) {
+	standard80, standard7d, missing := variant.Frames(d80, d7d)
+
+	data := decodeDataframes(time, standard80, standard7d)
+	data.setMissing(missing)
+	data.setRawDataframes(d80, d7d)
+
+	return data, missing
+}
+
+// Frames rearranges the frames into the standard layout, returns the frames and the names of
+// the channels whose bytes are missing from the frames
+func (variant *Variant) Frames(d80 []byte, d7d []byte) ([]byte, []byte, []string) {
+	standard80, present80 := rearrange(d80, size80, variant.Layout80)
+	standard7d, present7d := rearrange(d7d, size7d, variant.Layout7d)
+
+	missing := missingChannels(channels80, present80)
+	missing = append(missing, missingChannels(channels7d, present7d)...)
+
+	return standard80, standard7d, missing
+}
+
+// rearrange moves the bytes of the frame into the standard position, returns the standard
+// frame and the standard positions that are present
+func rearrange(frame []byte, size int, layout map[int]int) ([]byte, []bool) {
+	standard := make([]byte, size)
+	present := make([]bool, size)
+
+	for i, b := range frame {
+		position := i
+		if p, ok := layout[i]; ok {
+			position = p
+		}
+
+		if position < 0 || position >= size {
+			continue
+		}
+
+		standard[position] = b
+		present[position] = true
+	}
+
+	// the command and BytesinFrame are always the standard values so the frame decodes
+	if len(frame) > 0 {
+		standard[0] = frame[0]
+		standard[1] = byte(size - 1)
+	}
+
+	return standard, present
+}
+
+// missingChannels returns the channels that have a byte missing, in the order of the dataframe fields
+func missingChannels(channels []channelBytes, present []bool) []string {
+	var missing []string
+
+	for _, channel := range channels {
+		for _, position := range channel.positions {
+			if !present[position] {
+				missing = append(missing, channel.name)
+				break
+			}
+		}
+	}
+
+	return missing
+}
+
+// channelBytes is the MemsFCRData channel decoded from the bytes at the positions in the raw dataframe
+type channelBytes struct {
+	name      string
+	positions []int
+}
+
+// channelPositions returns the positions of the bytes of each MemsFCRData channel in the raw dataframe,
+// in the order of the dataframe fields
+func channelPositions(dataframe interface{}) []channelBytes {
+	var channels []channelBytes
+
+	t := reflect.TypeOf(dataframe)
+	offset := 0
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		size := int(field.Type.Size())
+
+		// the command and BytesinFrame aren't channels
+		if i > 1 {
+			name := field.Name
+			if n, ok := channelNames[name]; ok {
+				name = n
+			}
+
+			channel := channelBytes{name: name}
+			for p := offset; p < offset+size; p++ {
+				channel.positions = append(channel.positions, p)
+			}
+
+			channels = append(channels, channel)
+		}
+
+		offset += size
+	}
+
+	return channels
+}
+
+// setMissing records the channels that are missing from the dataframes and sets them to their zero value
+func (data *MemsFCRData) setMissing(channels []string) {
+	v := reflect.ValueOf(data).Elem()
+
+	for _, name := range channels {
+		if field := v.FieldByName(name); field.IsValid() {
+			field.Set(reflect.Zero(field.Type()))
+		}
+	}
+
+	data.missing = channels
+}
+
+// Missing returns the channels whose bytes were missing from the dataframes, their values are
+// zero and should be ignored
+func (data *MemsFCRData) Missing() []string {
+	return data.missing
+}
+
+// HasChannel returns false if the channel's bytes were missing from the dataframes
+func (data *MemsFCRData) HasChannel(name string) bool {
+	return !contains(data.missing, name)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
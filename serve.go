@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/server"
+	"github.com/andrewdjackson/memscene/utils"
+)
+
+// serve the scenario over a websocket, or the conversion and analysis API
+//
+// memscene serve [-addr localhost:8081] [-origins http://localhost:3000] <scenario>
+// memscene serve -api [-addr localhost:8081]
+func serve(args []string) {
+	var addr string
+	var api bool
+	var origins string
+
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.StringVar(&addr, "addr", "localhost:8081", "address to listen on")
+	flags.BoolVar(&api, "api", false, "serve the conversion and analysis API")
+	flags.StringVar(&origins, "origins", "", "comma separated origins of the pages allowed to connect to the replay stream, in addition to the server's own")
+	flags.Parse(args)
+
+	if (api && flags.NArg() != 0) || (!api && flags.NArg() != 1) {
+		fmt.Println("Usage of ./memscene serve [-addr host:port] <scenario> | -api")
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+
+	var handler http.Handler
+
+	if api {
+		handler = server.NewAPIServer().Handler()
+		utils.LogI.Printf("serving the api on http://%s/api/logs", addr)
+	} else {
+		scenario, err := scenarios.ConvertFile(flags.Arg(0))
+		if err != nil || scenario.Count == 0 {
+			utils.LogE.Fatalf("unable to load scenario %v", err)
+		}
+
+		replay := server.NewReplayServer(scenario)
+		if origins != "" {
+			replay.AllowedOrigins = strings.Split(origins, ",")
+		}
+
+		handler = replay.Handler()
+		utils.LogI.Printf("replaying %s on ws://%s/stream", flags.Arg(0), addr)
+	}
+
+	if err := http.ListenAndServe(addr, handler); err != nil {
+		utils.LogE.Fatalf("server stopped %s", err)
+	}
+}
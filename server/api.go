@@ -0,0 +1,392 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewdjackson/memscene/analysers"
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+)
+
+// The API server converts and analyses uploaded logs:
+//
+// POST /api/logs                        upload a log as the body or the multipart 'file' field
+// GET  /api/logs                        list the uploaded logs
+// GET  /api/logs/{id}                   details of the log
+// DELETE /api/logs/{id}                 remove the log
+// GET  /api/logs/{id}/download?format=  the converted scenario, csv (default), json or ndjson
+// GET  /api/logs/{id}/stats             min, max and mean of each channel
+// GET  /api/logs/{id}/faults            fault codes reported in the log
+// GET  /api/logs/{id}/analysers?name=   analyser reports, all analysers if no name, name=throttle for the pot sweep check,
+//                                       each report is the analyser's report structure
+// GET  /api/channels                    the channels with their units and metadata
+// GET  /api/channels/{name}             the channel
+//
+// the logs are held until they're deleted, when the stored logs exceed the limits
+// the least recently used logs are removed. The size of a log is its decompressed size
+// so compressed uploads count the same as the log they contain.
+
+const (
+	// maximumUploadSize limits the size of an uploaded log
+	maximumUploadSize = 64 << 20
+	// DefaultMaxLogs is the default limit on the number of stored logs
+	DefaultMaxLogs = 32
+	// DefaultMaxSize is the default limit on the total size of the stored logs
+	DefaultMaxSize = 256 << 20
+)
+
+// Log is an uploaded and converted log, the size is of the decompressed log
+type Log struct {
+	ID       int           `json:"id"`
+	Name     string        `json:"name"`
+	FileType string        `json:"filetype"`
+	Size     int64         `json:"size"`
+	Count    int           `json:"count"`
+	Duration time.Duration `json:"duration"`
+	Uploaded time.Time     `json:"uploaded"`
+	scenario *scenarios.Scenario
+	// used orders the logs by when they were last requested
+	used int
+}
+
+// AnalyserReport is the report from an analyser, the report is encoded as the analyser's
+// report structure, e.g. FuelTrimReport
+type AnalyserReport struct {
+	Name   string           `json:"name"`
+	Report analysers.Report `json:"report"`
+}
+
+// APIServer converts and analyses the uploaded logs, the logs are held in memory
+type APIServer struct {
+	// MaxLogs limits the number of stored logs
+	MaxLogs int
+	// MaxSize limits the total decompressed size in bytes of the stored logs
+	MaxSize int64
+	mutex   sync.Mutex
+	logs    map[int]*Log
+	next    int
+	used    int
+	size    int64
+}
+
+// NewAPIServer creates a new API server
+func NewAPIServer() *APIServer {
+	server := &APIServer{}
+	server.logs = make(map[int]*Log)
+	server.next = 1
+	server.MaxLogs = DefaultMaxLogs
+	server.MaxSize = DefaultMaxSize
+
+	return server
+}
+
+// Handler returns the http handler for the API
+func (server *APIServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/logs", server.upload)
+	mux.HandleFunc("GET /api/logs", server.list)
+	mux.HandleFunc("GET /api/logs/{id}", server.withLog(server.details))
+	mux.HandleFunc("DELETE /api/logs/{id}", server.withLog(server.remove))
+	mux.HandleFunc("GET /api/logs/{id}/download", server.withLog(server.download))
+	mux.HandleFunc("GET /api/logs/{id}/stats", server.withLog(server.stats))
+	mux.HandleFunc("GET /api/logs/{id}/faults", server.withLog(server.faults))
+	mux.HandleFunc("GET /api/logs/{id}/analysers", server.withLog(server.analyse))
+	mux.HandleFunc("GET /api/channels", server.channels)
+	mux.HandleFunc("GET /api/channels/{name}", server.channel)
+
+	return mux
+}
+
+// upload detects the type of the uploaded log and converts it
+func (server *APIServer) upload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maximumUploadSize)
+
+	body := io.Reader(r.Body)
+	name := r.URL.Query().Get("name")
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		defer file.Close()
+
+		body = file
+		name = header.Filename
+	}
+
+	l, err := convertUpload(name, body)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	server.mutex.Lock()
+	l.ID = server.next
+	server.next++
+	server.logs[l.ID] = l
+	server.size += l.Size
+	server.touch(l)
+	server.evict()
+	server.mutex.Unlock()
+
+	utils.LogI.Printf("%s uploaded %d '%s' (%s) %d dataframes", utils.ReceiveFromWebTrace, l.ID, l.Name, l.FileType, l.Count)
+
+	writeJSON(w, http.StatusCreated, l)
+}
+
+// convertUpload saves the upload to a temporary file so it can be detected and converted
+func convertUpload(name string, body io.Reader) (*Log, error) {
+	file, err := os.CreateTemp("", "memscene-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = io.Copy(file, body)
+	file.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := decompressedSize(file.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{Name: name, Size: size, Uploaded: time.Now()}
+	l.FileType = utils.GetFileType(file.Name())
+
+	if l.scenario, err = scenarios.ConvertFile(file.Name()); err != nil {
+		return nil, err
+	}
+
+	// the conversion skips lines it can't parse so a file that isn't a log has no dataframes
+	if l.scenario.Count == 0 {
+		return nil, fmt.Errorf("no dataframes found in '%s'", name)
+	}
+
+	stats := l.scenario.Stats()
+	l.Count = stats.Count
+	l.Duration = stats.Duration
+
+	return l, nil
+}
+
+// decompressedSize returns the size of the log after decompression
+func decompressedSize(filepath string) (int64, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	r, err := utils.Decompress(file)
+	if err != nil {
+		return 0, err
+	}
+
+	return io.Copy(io.Discard, r)
+}
+
+// touch marks the log as the most recently used, the caller must hold the lock
+func (server *APIServer) touch(l *Log) {
+	server.used++
+	l.used = server.used
+}
+
+// evict removes the least recently used logs until the stored logs are within
+// the limits, the most recent log is always kept. The caller must hold the lock
+func (server *APIServer) evict() {
+	for len(server.logs) > 1 && (len(server.logs) > server.MaxLogs || server.size > server.MaxSize) {
+		var oldest *Log
+
+		for _, l := range server.logs {
+			if oldest == nil || l.used < oldest.used {
+				oldest = l
+			}
+		}
+
+		server.delete(oldest)
+		utils.LogI.Printf("removed least recently used log %d '%s'", oldest.ID, oldest.Name)
+	}
+}
+
+// delete removes the log, the caller must hold the lock
+func (server *APIServer) delete(l *Log) {
+	delete(server.logs, l.ID)
+	server.size -= l.Size
+}
+
+// list returns the uploaded logs in the order they were uploaded
+func (server *APIServer) list(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	logs := make([]*Log, 0, len(server.logs))
+	for _, l := range server.logs {
+		logs = append(logs, l)
+	}
+	server.mutex.Unlock()
+
+	sort.Slice(logs, func(i, j int) bool { return logs[i].ID < logs[j].ID })
+
+	writeJSON(w, http.StatusOK, logs)
+}
+
+// withLog looks up the log from the id in the path
+func (server *APIServer) withLog(handler func(w http.ResponseWriter, r *http.Request, l *Log)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid log id '%s'", r.PathValue("id")))
+			return
+		}
+
+		server.mutex.Lock()
+		l, ok := server.logs[id]
+		if ok {
+			server.touch(l)
+		}
+		server.mutex.Unlock()
+
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Errorf("log %d not found", id))
+			return
+		}
+
+		handler(w, r, l)
+	}
+}
+
+func (server *APIServer) details(w http.ResponseWriter, r *http.Request, l *Log) {
+	writeJSON(w, http.StatusOK, l)
+}
+
+func (server *APIServer) remove(w http.ResponseWriter, r *http.Request, l *Log) {
+	server.mutex.Lock()
+	if server.logs[l.ID] == l {
+		server.delete(l)
+	}
+	server.mutex.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// download writes the converted scenario in the requested format
+func (server *APIServer) download(w http.ResponseWriter, r *http.Request, l *Log) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = scenarios.CSVFormat
+	}
+
+	contentType := map[string]string{
+		scenarios.CSVFormat:    "text/csv",
+		scenarios.JSONFormat:   "application/json",
+		scenarios.NDJSONFormat: "application/x-ndjson",
+	}[format]
+
+	if contentType == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown format '%s'", format))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": downloadName(l.Name, format)}))
+
+	if err := l.scenario.Write(w, format); err != nil {
+		utils.LogE.Printf("%s unable to write log %d %s", utils.SendToWebTrace, l.ID, err)
+	}
+}
+
+// downloadName is the name of the uploaded log without any path or quotes with the format extension
+func downloadName(name string, format string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+
+	name = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f || strings.ContainsRune("\"/\\", r) {
+			return -1
+		}
+		return r
+	}, name)
+
+	if name == "" || name == "." || name == ".." {
+		name = "log"
+	}
+
+	return fmt.Sprintf("%s.%s", name, format)
+}
+
+func (server *APIServer) stats(w http.ResponseWriter, r *http.Request, l *Log) {
+	writeJSON(w, http.StatusOK, l.scenario.Stats())
+}
+
+func (server *APIServer) faults(w http.ResponseWriter, r *http.Request, l *Log) {
+	faults := l.scenario.Faults()
+	if faults == nil {
+		faults = []*scenarios.Fault{}
+	}
+
+	writeJSON(w, http.StatusOK, faults)
+}
+
+// analyse runs the named analyser or all the analysers
+func (server *APIServer) analyse(w http.ResponseWriter, r *http.Request, l *Log) {
+	selected := analysers.All()
+
+	if name := r.URL.Query().Get("name"); name != "" {
+		analyser := analysers.Find(name)
+		if analyser == nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("unknown analyser '%s'", name))
+			return
+		}
+		selected = []analysers.Analyser{analyser}
+	}
+
+	var reports []AnalyserReport
+
+	for _, analyser := range selected {
+		reports = append(reports, AnalyserReport{Name: analyser.Name(), Report: analyser.Analyse(l.scenario)})
+	}
+
+	writeJSON(w, http.StatusOK, reports)
+}
+
+func (server *APIServer) channels(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, scenarios.Channels)
+}
+
+func (server *APIServer) channel(w http.ResponseWriter, r *http.Request) {
+	channel := scenarios.FindChannel(r.PathValue("name"))
+	if channel == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("channel '%s' not found", r.PathValue("name")))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, channel)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		utils.LogE.Printf("%s unable to write response %s", utils.SendToWebTrace, err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	utils.LogW.Printf("%s %d %s", utils.SendToWebTrace, status, err)
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
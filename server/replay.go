@@ -0,0 +1,313 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+	"github.com/gorilla/websocket"
+)
+
+// The replay server streams the scenario over a websocket at /stream. Each
+// MemsFCRData frame is sent as JSON at the time it was recorded. Clients control
+// the playback by sending control messages:
+//
+// {"action": "play"}
+// {"action": "pause"}
+// {"action": "seek", "time": "1m30s"} or {"action": "seek", "position": 120}
+// {"action": "speed", "speed": 2}
+//
+// every control message is answered with a status message.
+
+const (
+	// PlayAction starts or resumes the playback
+	PlayAction = "play"
+	// PauseAction pauses the playback
+	PauseAction = "pause"
+	// SeekAction moves the playback to the time or position
+	SeekAction = "seek"
+	// SpeedAction sets the playback speed
+	SpeedAction = "speed"
+)
+
+var (
+	errUnknownAction  = errors.New("unknown action")
+	errSeekOutOfRange = errors.New("seek beyond the end of the scenario")
+)
+
+// Control is a playback control message from the client
+type Control struct {
+	Action   string  `json:"action"`
+	Time     string  `json:"time,omitempty"`
+	Position int     `json:"position,omitempty"`
+	Speed    float64 `json:"speed,omitempty"`
+}
+
+// Status is sent to the client in response to a control message and at the end of the scenario
+type Status struct {
+	Status   string  `json:"status"`
+	Position int     `json:"position"`
+	Count    int     `json:"count"`
+	Speed    float64 `json:"speed"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// ReplayServer serves the scenario to the websocket clients
+type ReplayServer struct {
+	// AllowedOrigins are the origins of the pages allowed to connect in addition to the
+	// server's own origin, e.g. http://localhost:3000 for a dashboard served from another port
+	AllowedOrigins []string
+	scenario       *scenarios.Scenario
+	upgrader       websocket.Upgrader
+}
+
+// NewReplayServer creates a replay server for the scenario
+func NewReplayServer(scenario *scenarios.Scenario) *ReplayServer {
+	server := &ReplayServer{}
+	server.scenario = scenario
+	server.upgrader.CheckOrigin = server.checkOrigin
+
+	return server
+}
+
+// checkOrigin allows clients that aren't browsers, which don't send an origin, pages
+// from the server and pages from the allowed origins
+func (server *ReplayServer) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	for _, allowed := range server.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+
+	utils.LogW.Printf("%s rejected connection from origin %s", utils.ReceiveFromWebTrace, origin)
+
+	return false
+}
+
+// Handler returns the http handler for the replay server
+func (server *ReplayServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", server.stream)
+
+	return mux
+}
+
+// stream upgrades the connection to a websocket and replays the scenario, each client
+// has its own playback so clients can be paused and seeked independently
+func (server *ReplayServer) stream(w http.ResponseWriter, r *http.Request) {
+	conn, err := server.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		utils.LogE.Printf("unable to upgrade to a websocket %s", err)
+		return
+	}
+	defer conn.Close()
+
+	utils.LogI.Printf("%s client connected %s", utils.SendToWebTrace, r.RemoteAddr)
+
+	playback := scenarios.NewScenario()
+	playback.Memsdata = server.scenario.Memsdata
+	playback.Count = server.scenario.Count
+
+	newSession(conn, playback).run()
+
+	utils.LogI.Printf("%s client disconnected %s", utils.SendToWebTrace, r.RemoteAddr)
+}
+
+// session is the playback for a single websocket client
+type session struct {
+	conn     *websocket.Conn
+	playback *scenarios.Scenario
+	frames   <-chan *scenarios.MemsFCRData
+	cancel   context.CancelFunc
+}
+
+func newSession(conn *websocket.Conn, playback *scenarios.Scenario) *session {
+	return &session{conn: conn, playback: playback}
+}
+
+// run plays the scenario and handles the control messages until the client disconnects,
+// all the writes to the websocket are made from here
+func (session *session) run() {
+	controls := make(chan Control)
+	done := make(chan struct{})
+	defer close(done)
+
+	go session.readControls(controls, done)
+
+	session.play()
+	defer session.pause()
+
+	for {
+		select {
+		case data, ok := <-session.frames:
+			if !ok {
+				session.frames = nil
+				session.cancel()
+				if !session.send(session.status("ended", nil)) {
+					return
+				}
+				continue
+			}
+
+			if !session.send(data) {
+				return
+			}
+		case control, ok := <-controls:
+			if !ok {
+				return
+			}
+
+			utils.LogI.Printf("%s %+v", utils.ReceiveFromWebTrace, control)
+
+			if !session.send(session.control(control)) {
+				return
+			}
+		}
+	}
+}
+
+// readControls reads the control messages from the client, the channel is closed
+// when the client disconnects or the session ends
+func (session *session) readControls(controls chan<- Control, done <-chan struct{}) {
+	defer close(controls)
+
+	for {
+		var control Control
+
+		_, message, err := session.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if err = json.Unmarshal(message, &control); err != nil {
+			utils.LogW.Printf("%s invalid control message %s", utils.ReceiveFromWebTrace, message)
+			continue
+		}
+
+		select {
+		case controls <- control:
+		case <-done:
+			return
+		}
+	}
+}
+
+// control applies the control message and returns the status
+func (session *session) control(control Control) *Status {
+	var err error
+
+	switch control.Action {
+	case PlayAction:
+		session.play()
+	case PauseAction:
+		session.pause()
+	case SeekAction:
+		err = session.seek(control)
+	case SpeedAction:
+		err = session.playback.SetSpeed(control.Speed)
+	default:
+		err = errUnknownAction
+	}
+
+	state := "paused"
+	if session.frames != nil {
+		state = "playing"
+	}
+
+	return session.status(state, err)
+}
+
+// play starts the playback from the current position
+func (session *session) play() {
+	if session.frames != nil {
+		return
+	}
+
+	if _, ok := session.playback.Peek(); !ok {
+		// play from the beginning after the end of the scenario
+		session.playback.Reset()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	session.frames = session.playback.Play(ctx)
+	session.cancel = cancel
+}
+
+// pause stops the playback, frames already taken from the scenario are sent
+// so the playback resumes from the next frame
+func (session *session) pause() {
+	if session.frames == nil {
+		return
+	}
+
+	session.cancel()
+
+	for data := range session.frames {
+		session.send(data)
+	}
+
+	session.frames = nil
+}
+
+// seek moves the playback, playback continues from the new position if playing
+func (session *session) seek(control Control) error {
+	playing := session.frames != nil
+	session.pause()
+
+	var err error
+
+	if control.Time != "" {
+		var t time.Duration
+		if t, err = time.ParseDuration(control.Time); err == nil && !session.playback.Seek(t) {
+			err = errSeekOutOfRange
+		}
+	} else if !session.playback.SeekIndex(control.Position) {
+		err = errSeekOutOfRange
+	}
+
+	if playing {
+		session.play()
+	}
+
+	return err
+}
+
+func (session *session) status(state string, err error) *Status {
+	status := &Status{
+		Status:   state,
+		Position: session.playback.GetPosition(),
+		Count:    session.playback.Count,
+		Speed:    session.playback.Speed(),
+	}
+
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	return status
+}
+
+// send writes the message as JSON, returns false if the client has gone
+func (session *session) send(message interface{}) bool {
+	if err := session.conn.WriteJSON(message); err != nil {
+		utils.LogW.Printf("%s unable to send %s", utils.SendToWebTrace, err)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,352 @@
+package tests
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/andrewdjackson/memscene/analysers"
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/corbym/gocrest/has"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+)
+
+func TestFuelTrimLeanAtIdle(t *testing.T) {
+	scenario := scenarios.NewScenario()
+
+	for i := 0; i < 10; i++ {
+		// lean at idle, trims normal at load
+		scenario.Memsdata = append(scenario.Memsdata,
+			&scenarios.MemsFCRData{EngineRPM: 850, ManifoldAbsolutePressure: 32, ClosedLoop: true, LongTermFuelTrim: 15, ShortTermFuelTrim: 105},
+			&scenarios.MemsFCRData{EngineRPM: 2500, ManifoldAbsolutePressure: 65, ClosedLoop: true, LongTermFuelTrim: 2, ShortTermFuelTrim: 100},
+		)
+	}
+	scenario.Count = len(scenario.Memsdata)
+
+	report := analysers.NewFuelTrimAnalyser().Analyse(scenario).(*analysers.FuelTrimReport)
+
+	then.AssertThat(t, report.Diagnosis, is.EqualTo("lean at idle only"))
+	then.AssertThat(t, report.Causes[0].Description, is.ValueContaining("vacuum"))
+}
+
+func TestFuelTrimFallsBackToWarmIdle(t *testing.T) {
+	// the bundled logs were recorded without the ECU entering closed loop
+	scenario, err := scenarios.ConvertFile("../logfiles/nofaults-warm.csv")
+	then.AssertThat(t, err, is.Nil())
+
+	report := analysers.NewFuelTrimAnalyser().Analyse(scenario).(*analysers.FuelTrimReport)
+
+	then.AssertThat(t, report.ClosedLoopCount, is.EqualTo(0))
+	then.AssertThat(t, report.WarmIdleCount, is.GreaterThan(0))
+	then.AssertThat(t, report.Diagnosis, is.Not(is.ValueContaining("cannot be evaluated")))
+	then.AssertThat(t, report.String(), is.ValueContaining("warm idle dataframes"))
+}
+
+func TestFuelTrimUsesClosedLoopData(t *testing.T) {
+	// the readmems log has the loop indicator, 7dx0A, set while the short term trim is active
+	scenario, err := scenarios.ConvertFile("../data/readmems.data")
+	then.AssertThat(t, err, is.Nil())
+
+	report := analysers.NewFuelTrimAnalyser().Analyse(scenario).(*analysers.FuelTrimReport)
+
+	then.AssertThat(t, report.ClosedLoopCount, is.GreaterThan(0))
+	then.AssertThat(t, report.WarmIdleCount, is.EqualTo(0))
+}
+
+func TestThrottleCheckOnlyRunOnRequest(t *testing.T) {
+	for _, analyser := range analysers.All() {
+		then.AssertThat(t, analyser.Name(), is.Not(is.EqualTo("throttle")))
+	}
+
+	then.AssertThat(t, analysers.Find("throttle"), is.Not(is.Nil()))
+}
+
+func TestThrottleDropoutIsNotAnIdleSwitchDisagreement(t *testing.T) {
+	scenario := scenarios.NewScenario()
+
+	// sweep from closed to wide open and back with a dropout at 2V
+	voltages := []float32{0.5, 0.5, 1.0, 1.5, 2.0, 0, 2.5, 3.0, 3.5, 4.2, 4.6, 4.2, 3.0, 2.0, 1.0, 0.5}
+	for i, voltage := range voltages {
+		scenario.Memsdata = append(scenario.Memsdata, &scenarios.MemsFCRData{
+			Time:              fmt.Sprintf("08:00:%02d.000", i),
+			ThrottlePotSensor: voltage,
+			ThrottleAngle:     int(voltage * 20),
+			IdleSwitch:        voltage > 0 && voltage <= 0.5,
+		})
+	}
+	scenario.Count = len(scenario.Memsdata)
+
+	report := analysers.Find("throttle").Analyse(scenario).(*analysers.ThrottleReport)
+
+	then.AssertThat(t, report.IdleSwitchDisagreements, is.EqualTo(0))
+	then.AssertThat(t, report.DeadSpots, has.Length(1))
+	then.AssertThat(t, report.ClosedVoltage, is.EqualTo(float32(0.5)))
+	then.AssertThat(t, report.Pass, is.False())
+}
+
+// engineFrame is a dataframe recorded the seconds after 08:00:00
+func engineFrame(seconds int, rpm int, voltage float32) *scenarios.MemsFCRData {
+	return &scenarios.MemsFCRData{
+		Time:                     fmt.Sprintf("08:%02d:%02d", seconds/60, seconds%60),
+		EngineRPM:                rpm,
+		BatteryVoltage:           voltage,
+		CoolantTemp:              80,
+		ManifoldAbsolutePressure: 35,
+		IdleSwitch:               true,
+		CoilTime:                 4,
+	}
+}
+
+func newEngineScenario(frames ...*scenarios.MemsFCRData) *scenarios.Scenario {
+	scenario := scenarios.NewScenario()
+	scenario.Memsdata = frames
+	scenario.Count = len(frames)
+
+	return scenario
+}
+
+func TestCrankingTimeToStart(t *testing.T) {
+	scenario := newEngineScenario(
+		engineFrame(0, 0, 12.6),
+		engineFrame(1, 200, 10.5),
+		engineFrame(2, 250, 10.8),
+		engineFrame(3, 850, 14.0),
+		engineFrame(4, 860, 14.1),
+		engineFrame(5, 850, 14.1),
+		engineFrame(6, 850, 14.1),
+	)
+
+	report := analysers.NewCrankingAnalyser().Analyse(scenario).(*analysers.CrankingReport)
+
+	then.AssertThat(t, report.Events, has.Length(1))
+
+	event := report.Events[0]
+	then.AssertThat(t, event.Time, is.EqualTo("08:00:01"))
+	then.AssertThat(t, event.Started, is.True())
+	then.AssertThat(t, event.TimeToStart, is.EqualTo(2*time.Second))
+	then.AssertThat(t, event.RestingVoltage, is.EqualTo(float32(12.6)))
+	then.AssertThat(t, event.MinimumVoltage, is.EqualTo(float32(10.5)))
+	then.AssertThat(t, event.CrankingRPM, is.EqualTo(225))
+	then.AssertThat(t, event.Warnings, is.Empty())
+}
+
+func TestCrankingAtTheStartOfTheLog(t *testing.T) {
+	scenario := newEngineScenario(
+		engineFrame(0, 200, 10.5),
+		engineFrame(1, 250, 10.8),
+		engineFrame(2, 850, 14.0),
+		engineFrame(3, 860, 14.1),
+		engineFrame(4, 850, 14.1),
+		engineFrame(5, 850, 14.1),
+	)
+
+	report := analysers.NewCrankingAnalyser().Analyse(scenario).(*analysers.CrankingReport)
+
+	then.AssertThat(t, report.Events, has.Length(1))
+
+	// the resting voltage wasn't logged
+	event := report.Events[0]
+	then.AssertThat(t, event.Time, is.EqualTo("08:00:00"))
+	then.AssertThat(t, event.Started, is.True())
+	then.AssertThat(t, event.TimeToStart, is.EqualTo(2*time.Second))
+	then.AssertThat(t, event.RestingVoltage, is.EqualTo(float32(0)))
+	then.AssertThat(t, event.MinimumVoltage, is.EqualTo(float32(10.5)))
+	then.AssertThat(t, event.VoltageSag, is.EqualTo(float32(0)))
+	then.AssertThat(t, event.CrankingRPM, is.EqualTo(225))
+
+	// a log that starts with the engine running has no cranking event
+	running := newEngineScenario(engineFrame(0, 850, 14.0), engineFrame(1, 850, 14.0))
+	report = analysers.NewCrankingAnalyser().Analyse(running).(*analysers.CrankingReport)
+	then.AssertThat(t, report.Events, is.Empty())
+}
+
+func TestCrankingFailedStart(t *testing.T) {
+	scenario := newEngineScenario(
+		// fails to start on a weak battery
+		engineFrame(0, 0, 12.0),
+		engineFrame(1, 120, 9.0),
+		engineFrame(2, 130, 9.2),
+		engineFrame(3, 0, 11.8),
+		engineFrame(4, 0, 11.8),
+		// starts slowly on the second attempt
+		engineFrame(5, 200, 10.0),
+		engineFrame(6, 200, 10.0),
+		engineFrame(7, 200, 10.0),
+		engineFrame(8, 200, 10.0),
+		engineFrame(9, 850, 14.0),
+		engineFrame(10, 850, 14.0),
+		engineFrame(11, 850, 14.0),
+	)
+
+	report := analysers.NewCrankingAnalyser().Analyse(scenario).(*analysers.CrankingReport)
+
+	then.AssertThat(t, report.Events, has.Length(2))
+
+	failed := report.Events[0]
+	then.AssertThat(t, failed.Started, is.False())
+	then.AssertThat(t, failed.TimeToStart, is.EqualTo(time.Duration(0)))
+	then.AssertThat(t, failed.Warnings, has.Length(3))
+	then.AssertThat(t, failed.Warnings[0], is.ValueContaining("weak battery"))
+	then.AssertThat(t, failed.Warnings[1], is.ValueContaining("slow cranking"))
+	then.AssertThat(t, failed.Warnings[2], is.ValueContaining("stable idle"))
+
+	slow := report.Events[1]
+	then.AssertThat(t, slow.Time, is.EqualTo("08:00:05"))
+	then.AssertThat(t, slow.Started, is.True())
+	then.AssertThat(t, slow.TimeToStart, is.EqualTo(4*time.Second))
+	then.AssertThat(t, slow.Warnings, has.Length(1))
+	then.AssertThat(t, slow.Warnings[0], is.ValueContaining("slow start"))
+}
+
+func TestEnginePhase(t *testing.T) {
+	tests := []struct {
+		rpm        int
+		idleSwitch bool
+		kpa        float32
+		phase      analysers.EnginePhase
+	}{
+		{0, true, 100, analysers.EngineOff},
+		{150, true, 95, analysers.Cranking},
+		{399, false, 90, analysers.Cranking},
+		{850, true, 32, analysers.Idle},
+		{1100, false, 40, analysers.Idle},
+		{1100, false, 60, analysers.Load},
+		{1200, true, 30, analysers.Load},
+		{3000, false, 80, analysers.Load},
+	}
+
+	for _, test := range tests {
+		data := &scenarios.MemsFCRData{EngineRPM: test.rpm, IdleSwitch: test.idleSwitch, ManifoldAbsolutePressure: test.kpa}
+		then.AssertThat(t, analysers.GetEnginePhase(data), is.EqualTo(test.phase))
+	}
+
+	then.AssertThat(t, analysers.Idle.IsRunning(), is.True())
+	then.AssertThat(t, analysers.Cranking.IsRunning(), is.False())
+}
+
+// chargingScenario is a start with the running voltages at idle
+func chargingScenario(resting float32, running ...float32) *scenarios.Scenario {
+	scenario := newEngineScenario(
+		engineFrame(0, 0, resting),
+		engineFrame(1, 0, resting),
+		engineFrame(2, 200, 10.5),
+		engineFrame(3, 200, 10.2),
+	)
+
+	for i, voltage := range running {
+		scenario.Memsdata = append(scenario.Memsdata, engineFrame(4+i, 850, voltage))
+	}
+	scenario.Count = len(scenario.Memsdata)
+
+	return scenario
+}
+
+func TestChargingHealthy(t *testing.T) {
+	scenario := chargingScenario(12.6, 14.1, 14.2, 14.1, 14.0, 14.1, 14.2)
+
+	report := analysers.NewChargingAnalyser().Analyse(scenario).(*analysers.ChargingReport)
+
+	then.AssertThat(t, report.RestingVoltage, is.EqualTo(float32(12.6)))
+	then.AssertThat(t, report.MinimumCrankVoltage, is.EqualTo(float32(10.2)))
+	then.AssertThat(t, report.IdleVoltage, is.GreaterThan(float32(14.0)))
+	then.AssertThat(t, report.Ripple, is.LessThan(float32(0.1)))
+	then.AssertThat(t, report.Dropouts, is.Empty())
+	then.AssertThat(t, report.Warnings, is.Empty())
+}
+
+func TestChargingUndercharge(t *testing.T) {
+	scenario := chargingScenario(11.9, 12.8, 12.7, 12.8, 12.7)
+
+	report := analysers.NewChargingAnalyser().Analyse(scenario).(*analysers.ChargingReport)
+
+	then.AssertThat(t, report.Warnings, has.Length(2))
+	then.AssertThat(t, report.Warnings[0], is.ValueContaining("battery discharged"))
+	then.AssertThat(t, report.Warnings[1], is.ValueContaining("undercharging at idle"))
+}
+
+func TestChargingRippleAndDropouts(t *testing.T) {
+	ripple := chargingScenario(12.6, 13.7, 14.5, 13.7, 14.5, 13.7, 14.5)
+
+	report := analysers.NewChargingAnalyser().Analyse(ripple).(*analysers.ChargingReport)
+
+	then.AssertThat(t, report.Ripple, is.GreaterThan(float32(analysers.RippleLimit)))
+	then.AssertThat(t, report.Dropouts, is.Empty())
+	then.AssertThat(t, report.Warnings, has.Length(1))
+	then.AssertThat(t, report.Warnings[0], is.ValueContaining("unstable charging voltage"))
+
+	// a steady voltage at idle and under load isn't ripple
+	steady := chargingScenario(12.6, 13.8, 13.8, 13.8)
+	for i := 0; i < 3; i++ {
+		load := engineFrame(7+i, 3000, 14.4)
+		load.IdleSwitch = false
+		load.ManifoldAbsolutePressure = 70
+		steady.Memsdata = append(steady.Memsdata, load)
+	}
+	steady.Count = len(steady.Memsdata)
+
+	report = analysers.NewChargingAnalyser().Analyse(steady).(*analysers.ChargingReport)
+
+	then.AssertThat(t, report.LoadVoltage, is.EqualTo(float32(14.4)))
+	then.AssertThat(t, report.Ripple, is.LessThan(float32(0.01)))
+	then.AssertThat(t, report.Warnings, is.Empty())
+
+	dropout := chargingScenario(12.6, 14.1, 14.1, 12.9, 14.1, 14.1)
+
+	report = analysers.NewChargingAnalyser().Analyse(dropout).(*analysers.ChargingReport)
+
+	then.AssertThat(t, report.Dropouts, has.Length(1))
+	then.AssertThat(t, report.Dropouts[0], is.EqualTo("08:00:06"))
+}
+
+// idleScenario is a warm idle with the IAC position varying around the position
+func idleScenario(position int) *scenarios.Scenario {
+	scenario := scenarios.NewScenario()
+
+	for i := 0; i < 20; i++ {
+		data := engineFrame(i, 850+i%3*10, 14.1)
+		data.CoolantTemp = 85
+		data.IACPosition = position + i%3
+		data.LongTermFuelTrim = i % 2
+		scenario.Memsdata = append(scenario.Memsdata, data)
+	}
+
+	scenario.Count = len(scenario.Memsdata)
+
+	return scenario
+}
+
+func TestCompareFindsDifferences(t *testing.T) {
+	baseline := idleScenario(30)
+	suspect := idleScenario(50)
+
+	// cold frames only in the suspect aren't compared
+	cold := engineFrame(30, 1200, 14.1)
+	cold.CoolantTemp = 20
+	suspect.Memsdata = append(suspect.Memsdata, cold, cold, cold)
+
+	report := analysers.Compare(baseline, suspect)
+
+	then.AssertThat(t, report.Groups, is.EqualTo(1))
+	then.AssertThat(t, report.Differences, has.Length(1))
+
+	difference := report.Differences[0]
+	then.AssertThat(t, difference.Channel, is.EqualTo("IACPosition"))
+	then.AssertThat(t, difference.Phase, is.EqualTo(analysers.Idle))
+	then.AssertThat(t, difference.CoolantBand, is.EqualTo(80))
+	then.AssertThat(t, math.Round(difference.Difference), is.EqualTo(20.0))
+	then.AssertThat(t, difference.Unit, is.EqualTo("steps"))
+	then.AssertThat(t, difference.String(), is.ValueContaining("IACPosition 20.0 steps higher at 80-89°C idle"))
+}
+
+func TestCompareBaselineWithItself(t *testing.T) {
+	baseline, err := scenarios.ConvertFile("../logfiles/nofaults-warm.csv")
+	then.AssertThat(t, err, is.Nil())
+
+	report := analysers.Compare(baseline, baseline)
+
+	then.AssertThat(t, report.Groups, is.GreaterThan(0))
+	then.AssertThat(t, report.Differences, is.Empty())
+	then.AssertThat(t, report.String(), is.ValueContaining("no significant differences"))
+}
@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+	"github.com/corbym/gocrest/has"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+)
+
+// capture builds a serial capture, each record is written 10ms after the previous record
+type capture struct {
+	buffer bytes.Buffer
+	time   time.Time
+}
+
+func newCapture() *capture {
+	c := &capture{time: time.Date(2021, 1, 1, 8, 0, 0, 0, time.Local)}
+	c.buffer.WriteString(utils.SerialCaptureHeader)
+
+	return c
+}
+
+func (c *capture) record(data []byte) {
+	c.time = c.time.Add(10 * time.Millisecond)
+
+	binary.Write(&c.buffer, binary.BigEndian, uint64(c.time.UnixMicro()))
+	binary.Write(&c.buffer, binary.BigEndian, uint16(len(data)))
+	c.buffer.Write(data)
+}
+
+// pause leaves a gap on the line longer than the time to send a frame
+func (c *capture) pause() {
+	c.time = c.time.Add(time.Second)
+}
+
+// request writes the echo of the command sent and the response split over two reads,
+// the response starts with the ECU's echo of the command
+func (c *capture) request(frame []byte) {
+	c.record([]byte{frame[0]})
+	c.record(frame[:10])
+	c.record(frame[10:])
+}
+
+func (c *capture) save(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "capture.bin")
+	then.AssertThat(t, os.WriteFile(path, c.buffer.Bytes(), 0644), is.Nil())
+
+	return path
+}
+
+func readmemsFrames(t *testing.T) ([]byte, []byte) {
+	scenario, err := scenarios.ConvertFile("../data/readmems.data")
+	then.AssertThat(t, err, is.Nil())
+
+	d80, _ := hex.DecodeString(scenario.Memsdata[0].Dataframe80)
+	d7d, _ := hex.DecodeString(scenario.Memsdata[0].Dataframe7d)
+
+	return d80, d7d
+}
+
+func TestConvertSerialCapture(t *testing.T) {
+	d80, d7d := readmemsFrames(t)
+
+	c := newCapture()
+	// the initialisation and heartbeat responses are skipped
+	c.record([]byte{0xCA, 0xCA, 0x75, 0x75, 0xD0, 0xD0, 0x99, 0x00, 0x02, 0x03})
+
+	for i := 0; i < 5; i++ {
+		c.pause()
+		c.request(d80)
+		c.request(d7d)
+		c.record([]byte{0xF4, 0xF4, 0x00})
+	}
+
+	path := c.save(t)
+	then.AssertThat(t, utils.GetFileType(path), is.EqualTo(utils.SerialCaptureFile))
+
+	reader, closer, err := scenarios.OpenFrameReader(path)
+	then.AssertThat(t, err, is.Nil())
+	defer closer.Close()
+
+	scenario, err := scenarios.ReadAll(reader)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, scenario.Count, is.EqualTo(5))
+	then.AssertThat(t, scenario.Memsdata[0].Dataframe80, is.EqualTo(hex.EncodeToString(d80)))
+	then.AssertThat(t, scenario.Memsdata[0].Dataframe7d, is.EqualTo(hex.EncodeToString(d7d)))
+	then.AssertThat(t, scenario.Memsdata[0].Time, is.EqualTo("08:00:01.030"))
+
+	report := reader.(scenarios.ParseReporter).Report()
+	then.AssertThat(t, report.Frames, is.EqualTo(5))
+	then.AssertThat(t, report.HasProblems(), is.False())
+	then.AssertThat(t, report.Skipped, is.EqualTo(10+5*(2+3)))
+}
+
+func TestSerialCaptureReportsDroppedFrames(t *testing.T) {
+	d80, d7d := readmemsFrames(t)
+
+	c := newCapture()
+
+	// a 0x7d response without the 0x80 response
+	c.pause()
+	c.request(d7d)
+
+	// a 0x7d response that lost bytes, the 0x80 is dropped as it has no pair
+	c.pause()
+	c.request(d80)
+	c.record([]byte{0x7D})
+	c.record(d7d[:12])
+	c.pause()
+
+	// a complete dataframe
+	c.request(d80)
+	c.request(d7d)
+
+	// a 0x80 response truncated at the end of the capture
+	c.pause()
+	c.record([]byte{0x80})
+	c.record(d80[:20])
+
+	reader, closer, err := scenarios.OpenFrameReader(c.save(t))
+	then.AssertThat(t, err, is.Nil())
+	defer closer.Close()
+
+	scenario, err := scenarios.ReadAll(reader)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, scenario.Count, is.EqualTo(1))
+	then.AssertThat(t, scenario.Memsdata[0].Dataframe7d, is.EqualTo(hex.EncodeToString(d7d)))
+
+	report := reader.(scenarios.ParseReporter).Report()
+	then.AssertThat(t, report.Frames, is.EqualTo(1))
+	then.AssertThat(t, report.Dropped, is.EqualTo(2))
+	then.AssertThat(t, report.Corrupted, is.EqualTo(2))
+	then.AssertThat(t, report.Problems, has.Length(4))
+}
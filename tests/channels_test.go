@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/server"
+	"github.com/corbym/gocrest/has"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+)
+
+func TestChannelsDescribeEveryField(t *testing.T) {
+	var fields []string
+
+	for _, field := range reflect.VisibleFields(reflect.TypeOf(scenarios.MemsFCRData{})) {
+		if field.IsExported() {
+			fields = append(fields, field.Name)
+		}
+	}
+
+	then.AssertThat(t, scenarios.Channels, has.Length(len(fields)))
+
+	for i, channel := range scenarios.Channels {
+		then.AssertThat(t, channel.Name, is.EqualTo(fields[i]))
+		then.AssertThat(t, channel.DisplayName, is.Not(is.EqualTo("")))
+		then.AssertThat(t, channel.Description, is.Not(is.EqualTo("")))
+		then.AssertThat(t, channel.Min <= channel.Max, is.True())
+	}
+
+	coolant := scenarios.FindChannel("CoolantTemp")
+	then.AssertThat(t, coolant.Label(), is.EqualTo("Coolant Temperature (°C)"))
+	then.AssertThat(t, coolant.Scaling(), is.EqualTo("x - 55"))
+	then.AssertThat(t, coolant.InRange(90), is.True())
+	then.AssertThat(t, scenarios.FindChannel("CoilTime").Unit, is.EqualTo("ms"))
+	then.AssertThat(t, scenarios.FindChannel("DTC1").Column, is.EqualTo(""))
+	then.AssertThat(t, scenarios.FindChannel("unknown") == nil, is.True())
+}
+
+func TestChannelsMatchTheDecoders(t *testing.T) {
+	scenario, err := scenarios.ConvertFile("../data/readmems.data")
+	then.AssertThat(t, err, is.Nil())
+
+	offset := scenarios.FindChannel("IdleSpeedOffset")
+
+	for _, data := range scenario.Memsdata {
+		d80, _ := hex.DecodeString(data.Dataframe80)
+		d7d, _ := hex.DecodeString(data.Dataframe7d)
+		decoded := scenarios.DecodeDataframes(data.Time, d80, d7d)
+
+		then.AssertThat(t, data.IdleSpeedOffset, is.EqualTo(decoded.IdleSpeedOffset))
+		// 7dx13 follows the command byte
+		then.AssertThat(t, float64(data.IdleSpeedOffset), is.EqualTo(float64(d7d[1+0x13])*offset.Scale+offset.Offset))
+	}
+}
+
+func TestCSVHeaderFromChannels(t *testing.T) {
+	scenario, err := scenarios.ConvertFile("../data/memsfcr.csv")
+	then.AssertThat(t, err, is.Nil())
+
+	var buffer bytes.Buffer
+	then.AssertThat(t, scenario.Write(&buffer, scenarios.CSVFormat), is.Nil())
+
+	header := strings.SplitN(buffer.String(), "\n", 2)[0]
+	then.AssertThat(t, header, is.EqualTo(strings.Join(scenarios.Columns(), ",")))
+	then.AssertThat(t, strings.HasPrefix(header, "#time,80x01-02_engine-rpm,80x03_coolant_temp,"), is.True())
+}
+
+func TestAPIServerChannels(t *testing.T) {
+	s := httptest.NewServer(server.NewAPIServer().Handler())
+	defer s.Close()
+
+	var channels []*scenarios.Channel
+	getJSON(t, s.URL+"/api/channels", &channels)
+	then.AssertThat(t, channels, has.Length(len(scenarios.Channels)))
+
+	var channel scenarios.Channel
+	getJSON(t, s.URL+"/api/channels/LambdaVoltage", &channel)
+	then.AssertThat(t, channel.Unit, is.EqualTo("mV"))
+	then.AssertThat(t, channel.Source, is.EqualTo("7dx06"))
+
+	response, err := http.Get(s.URL + "/api/channels/unknown")
+	then.AssertThat(t, err, is.Nil())
+	response.Body.Close()
+	then.AssertThat(t, response.StatusCode, is.EqualTo(http.StatusNotFound))
+}
@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+	"github.com/corbym/gocrest/has"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"github.com/klauspost/compress/zstd"
+)
+
+func readLog(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(filepath.Join("../data", name))
+	then.AssertThat(t, err, is.Nil())
+
+	return data
+}
+
+func TestConvertCompressedLogs(t *testing.T) {
+	dir := t.TempDir()
+	log := readLog(t, "memsroscov2.txt")
+
+	expected, _ := scenarios.ConvertFile("../data/memsroscov2.txt")
+
+	gz, _ := os.Create(filepath.Join(dir, "log.txt.gz"))
+	gzw := gzip.NewWriter(gz)
+	gzw.Write(log)
+	gzw.Close()
+	gz.Close()
+
+	zst, _ := os.Create(filepath.Join(dir, "log.txt.zst"))
+	zstw, _ := zstd.NewWriter(zst)
+	zstw.Write(log)
+	zstw.Close()
+	zst.Close()
+
+	for _, file := range []string{filepath.Join(dir, "log.txt.gz"), filepath.Join(dir, "log.txt.zst")} {
+		then.AssertThat(t, utils.GetFileType(file), is.EqualTo(utils.MemsRoscoFilev2))
+
+		scenario, err := scenarios.ConvertFile(file)
+		then.AssertThat(t, err, is.Nil())
+		then.AssertThat(t, scenario.Count, is.EqualTo(expected.Count))
+	}
+
+	scenario, err := scenarios.ConvertFile("../data/memsrosco.txt.bz2")
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, scenario.Count, is.GreaterThan(0))
+
+	then.AssertThat(t, scenarios.OutputName("log.txt.gz", scenarios.CSVFormat), is.EqualTo("log.txt.output.csv"))
+}
+
+func TestConvertArchives(t *testing.T) {
+	dir := t.TempDir()
+
+	// zip archive of a readmems log, a mems-rosco log and a text file that isn't a log
+	zipfile, _ := os.Create(filepath.Join(dir, "logs.zip"))
+	zw := zip.NewWriter(zipfile)
+	for name, content := range map[string][]byte{
+		"readmems.data":       readLog(t, "readmems.data"),
+		"road test/rosco.txt": readLog(t, "memsrosco.txt"),
+		"notes.txt":           []byte("a note"),
+	} {
+		w, _ := zw.Create(name)
+		w.Write(content)
+	}
+	zw.Close()
+	zipfile.Close()
+
+	// tar.gz archive of a mems-rosco v2 log
+	targz, _ := os.Create(filepath.Join(dir, "logs.tar.gz"))
+	gzw := gzip.NewWriter(targz)
+	tw := tar.NewWriter(gzw)
+	log := readLog(t, "memsroscov2.txt")
+	tw.WriteHeader(&tar.Header{Name: "v2.txt", Mode: 0644, Size: int64(len(log)), Typeflag: tar.TypeReg})
+	tw.Write(log)
+	tw.Close()
+	gzw.Close()
+	targz.Close()
+
+	then.AssertThat(t, utils.IsArchive(filepath.Join(dir, "logs.zip")), is.True())
+	then.AssertThat(t, utils.IsArchive(filepath.Join(dir, "logs.tar.gz")), is.True())
+	then.AssertThat(t, utils.IsArchive("../data/memsroscov2.txt"), is.False())
+
+	out := t.TempDir()
+
+	results, err := scenarios.ConvertArchive(filepath.Join(dir, "logs.zip"), out, scenarios.CSVFormat)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, results, has.Length(3))
+
+	_, err = os.Stat(filepath.Join(out, "road test", "rosco.txt.output.csv"))
+	then.AssertThat(t, err, is.Nil())
+
+	// the batch conversion converts each log in the archives
+	report, err := scenarios.ConvertDirectory(dir, filepath.Join(dir, "batch"), 2)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, report.Count(scenarios.Converted), is.EqualTo(3))
+	then.AssertThat(t, report.Count(scenarios.Skipped), is.GreaterThan(0))
+
+	_, err = os.Stat(filepath.Join(dir, "batch", "v2.txt.output.csv"))
+	then.AssertThat(t, err, is.Nil())
+}
+
+func TestConvertArchiveRejectsUnsafeNames(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+
+	zipfile, _ := os.Create(filepath.Join(dir, "logs.zip"))
+	zw := zip.NewWriter(zipfile)
+	for _, name := range []string{"../escaped.txt", "road test/../../escaped2.txt", "/absolute.txt", "rosco.txt"} {
+		w, _ := zw.Create(name)
+		w.Write(readLog(t, "memsrosco.txt"))
+	}
+	zw.Close()
+	zipfile.Close()
+
+	results, err := scenarios.ConvertArchive(filepath.Join(dir, "logs.zip"), out, scenarios.CSVFormat)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, results, has.Length(4))
+
+	for _, result := range results[:3] {
+		then.AssertThat(t, result.Status, is.EqualTo(scenarios.Failed))
+		then.AssertThat(t, result.Err, is.Not(is.Nil()))
+	}
+
+	then.AssertThat(t, results[3].Status, is.EqualTo(scenarios.Converted))
+
+	// nothing is written outside the output directory
+	for _, name := range []string{"escaped.txt.output.csv", "escaped2.txt.output.csv", "absolute.txt.output.csv"} {
+		_, err = os.Stat(filepath.Join(dir, name))
+		then.AssertThat(t, os.IsNotExist(err), is.True())
+	}
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/andrewdjackson/memscene/emulator"
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+)
+
+func getEmulator(t *testing.T) *emulator.Emulator {
+	r := scenarios.NewMemsFCR()
+	scenario := r.Convert(getFilePath("../data/memsfcr.csv"))
+	then.AssertThat(t, scenario.Count, is.GreaterThan(0))
+
+	return emulator.NewEmulator(scenario)
+}
+
+func TestEmulatorInitialisation(t *testing.T) {
+	e := getEmulator(t)
+
+	then.AssertThat(t, e.Respond(emulator.InitCommandA), is.EqualTo([]byte{0xCA}))
+	then.AssertThat(t, e.Respond(emulator.InitCommandB), is.EqualTo([]byte{0x75}))
+	then.AssertThat(t, e.Respond(emulator.HeartbeatCommand), is.EqualTo([]byte{0xF4, 0x00}))
+	then.AssertThat(t, e.Respond(emulator.ECUIDCommand), is.EqualTo([]byte{0xD0, 0x99, 0x00, 0x02, 0x03}))
+	then.AssertThat(t, len(e.Respond(emulator.Dataframe80Command)), is.EqualTo(0x1D))
+	then.AssertThat(t, len(e.Respond(emulator.Dataframe7dCommand)), is.EqualTo(0x21))
+}
+
+func TestEmulatorActuators(t *testing.T) {
+	e := getEmulator(t)
+
+	then.AssertThat(t, e.Respond(emulator.FuelPumpOn), is.EqualTo([]byte{emulator.FuelPumpOn, 0x00}))
+	then.AssertThat(t, e.State.FuelPump, is.True())
+	then.AssertThat(t, e.Respond(emulator.FuelPumpOff), is.EqualTo([]byte{emulator.FuelPumpOff, 0x00}))
+	then.AssertThat(t, e.State.FuelPump, is.False())
+
+	e.Respond(emulator.Fan1On)
+	then.AssertThat(t, e.State.Fan1, is.True())
+}
+
+func TestEmulatorIACSteps(t *testing.T) {
+	e := getEmulator(t)
+
+	// the recorded iac position 80x12 is 0x82
+	then.AssertThat(t, e.Respond(emulator.Dataframe80Command)[0x13], is.EqualTo(byte(0x82)))
+	then.AssertThat(t, e.Respond(emulator.OpenIAC), is.EqualTo([]byte{emulator.OpenIAC, 0x83}))
+	then.AssertThat(t, e.Respond(emulator.OpenIAC), is.EqualTo([]byte{emulator.OpenIAC, 0x84}))
+	then.AssertThat(t, e.Respond(emulator.Dataframe80Command)[0x13], is.EqualTo(byte(0x84)))
+	then.AssertThat(t, e.Respond(emulator.CloseIAC), is.EqualTo([]byte{emulator.CloseIAC, 0x83}))
+}
+
+func TestEmulatorClearFaults(t *testing.T) {
+	e := getEmulator(t)
+
+	then.AssertThat(t, e.Respond(emulator.ClearFaultsCommand), is.EqualTo([]byte{0xCC, 0x00}))
+
+	df80 := e.Respond(emulator.Dataframe80Command)
+	df7d := e.Respond(emulator.Dataframe7dCommand)
+
+	then.AssertThat(t, df80[0x0E], is.EqualTo(byte(0)))
+	then.AssertThat(t, df80[0x0F], is.EqualTo(byte(0)))
+	then.AssertThat(t, df7d[0x06], is.EqualTo(byte(0)))
+}
+
+func TestEmulatorAdjustments(t *testing.T) {
+	e := getEmulator(t)
+	e.Respond(emulator.Dataframe80Command)
+
+	// recorded idle speed offset 7dx13 is 0x88
+	then.AssertThat(t, e.Respond(emulator.IdleSpeedIncrement), is.EqualTo([]byte{emulator.IdleSpeedIncrement, 0x89}))
+	then.AssertThat(t, e.Respond(emulator.Dataframe7dCommand)[0x14], is.EqualTo(byte(0x89)))
+	then.AssertThat(t, e.Respond(emulator.ResetAdjustments), is.EqualTo([]byte{emulator.ResetAdjustments, 0x00}))
+	then.AssertThat(t, e.Respond(emulator.Dataframe7dCommand)[0x14], is.EqualTo(byte(0x88)))
+}
+
+func TestEmulatorDoesNotChangeTheScenario(t *testing.T) {
+	r := scenarios.NewMemsFCR()
+	scenario := r.Convert(getFilePath("../data/memsfcr.csv"))
+
+	e := emulator.NewEmulator(scenario)
+
+	// the emulator loops round the scenario
+	for i := 0; i <= scenario.Count; i++ {
+		then.AssertThat(t, len(e.Respond(emulator.Dataframe80Command)), is.EqualTo(0x1D))
+	}
+
+	then.AssertThat(t, scenario.GetPosition(), is.EqualTo(0))
+
+	scenario.SeekIndex(scenario.Count - 1)
+	scenario.Next()
+	_, ok := scenario.Next()
+	then.AssertThat(t, ok, is.False())
+}
+
+func TestPseudoTerminalKeepsTheTTYOpen(t *testing.T) {
+	port, tty, err := emulator.OpenPseudoTerminal()
+	if err != nil {
+		t.Skipf("pseudo terminals unavailable %s", err)
+	}
+	defer port.Close()
+	defer tty.Close()
+
+	// the tty is held by the caller and isn't closed by its finalizer
+	runtime.GC()
+
+	_, err = tty.Write([]byte{emulator.InitCommandA})
+	then.AssertThat(t, err, is.Nil())
+
+	command := make([]byte, 1)
+	_, err = port.Read(command)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, command[0], is.EqualTo(emulator.InitCommandA))
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andrewdjackson/memscene/generator"
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/corbym/gocrest/has"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+)
+
+func TestGeneratorIsRepeatable(t *testing.T) {
+	a, err := generator.NewGenerator(42).Generate()
+	then.AssertThat(t, err, is.Nil())
+	b, _ := generator.NewGenerator(42).Generate()
+
+	then.AssertThat(t, a.Count, is.EqualTo(b.Count))
+	then.AssertThat(t, a.Diff(b, scenarios.MatchByIndex).Fields, has.Length(0))
+
+	// generating again from the same generator starts from the seed
+	g := generator.NewGenerator(42)
+	g.Generate()
+	c, _ := g.Generate()
+
+	then.AssertThat(t, c.Count, is.EqualTo(a.Count))
+	then.AssertThat(t, a.Diff(c, scenarios.MatchByIndex).Fields, has.Length(0))
+}
+
+func TestGeneratorRejectsInvalidIntervals(t *testing.T) {
+	g := generator.NewGenerator(1)
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		g.Interval = interval
+
+		_, err := g.Generate()
+		then.AssertThat(t, err, is.Not(is.Nil()))
+	}
+}
+
+func TestGeneratorWarmsUp(t *testing.T) {
+	g := generator.NewGenerator(1)
+	g.AmbientTemp = 5
+	g.Profile = []generator.Segment{
+		{Kind: generator.Crank, Duration: 2 * time.Second},
+		{Kind: generator.Drive, Duration: 20 * time.Minute},
+		{Kind: generator.Idle, Duration: 2 * time.Minute},
+	}
+
+	scenario, err := g.Generate()
+	then.AssertThat(t, err, is.Nil())
+
+	first := scenario.Memsdata[0]
+	last := scenario.Memsdata[scenario.Count-1]
+
+	then.AssertThat(t, first.CoolantTemp, is.LessThan(10))
+	then.AssertThat(t, last.CoolantTemp, is.GreaterThan(80))
+	then.AssertThat(t, last.ClosedLoop, is.True())
+	then.AssertThat(t, last.EngineRPM, is.LessThan(1000))
+	then.AssertThat(t, len(last.Dataframe80), is.EqualTo(58))
+	then.AssertThat(t, len(last.Dataframe7d), is.EqualTo(66))
+}
+
+func TestGeneratorParseProfile(t *testing.T) {
+	segments, err := generator.ParseProfile("off:5s,crank:2s,idle:3m")
+
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, segments, has.Length(3))
+	then.AssertThat(t, segments[2].Duration, is.EqualTo(3*time.Minute))
+
+	_, err = generator.ParseProfile("fly:5s")
+	then.AssertThat(t, err, is.Not(is.Nil()))
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+)
+
+// kwpMessage builds a KWP2000 message with the checksum, the length is in a separate byte if long is true
+func kwpMessage(long bool, target byte, source byte, data ...byte) []byte {
+	message := append([]byte{0x80 | byte(len(data)), target, source}, data...)
+	if long {
+		message = append([]byte{0x80, target, source, byte(len(data))}, data...)
+	}
+
+	var checksum byte
+	for _, b := range message {
+		checksum += b
+	}
+
+	return append(message, checksum)
+}
+
+// kwpRequest writes the echo of the request for the local identifier and the response
+func (c *capture) kwpRequest(long bool, address byte, localID byte, data ...byte) {
+	c.record(kwpMessage(false, address, 0xF1, 0x21, localID))
+	c.record(kwpMessage(long, 0xF1, address, append([]byte{0x61, localID}, data...)...))
+}
+
+func TestConvertRejectsKWPCaptures(t *testing.T) {
+	for _, long := range []bool{false, true} {
+		c := newCapture()
+		c.pause()
+		c.kwpRequest(long, 0x16, 0x01, 0x03, 0x52, 0x82, 0x46)
+
+		_, err := scenarios.ConvertFile(c.save(t))
+		then.AssertThat(t, err, is.Not(is.Nil()))
+		then.AssertThat(t, err.Error(), is.ValueContaining("KWP2000 capture from ECU address 0x16"))
+	}
+}
+
+func TestConvertIgnoresCorruptedKWPResponses(t *testing.T) {
+	d80, d7d := readmemsFrames(t)
+
+	// a response with an invalid checksum isn't taken as a KWP2000 capture
+	c := newCapture()
+	corrupted := kwpMessage(false, 0xF1, 0x16, 0x61, 0x01, 0x03, 0x52)
+	corrupted[len(corrupted)-1] ^= 0xFF
+	c.record(corrupted)
+	c.pause()
+	c.request(d80)
+	c.request(d7d)
+
+	scenario, err := scenarios.ConvertFile(c.save(t))
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, scenario.Count, is.EqualTo(1))
+}
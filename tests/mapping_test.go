@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+)
+
+func getMemsRoscoData() *scenarios.MemsRoscoData {
+	return &scenarios.MemsRoscoData{
+		Time:                     "18:49:07",
+		EngineRPM:                850,
+		CoolantTemp:              88,
+		BatteryVoltage:           14.1,
+		IdleSwitch:               1,
+		ParkNeutralSwitch:        1,
+		DTC0:                     2,
+		IdleSpeedDeviation:       1500,
+		CrankshaftPositionSensor: 1,
+		IgnitionSwitch:           1,
+		ClosedLoop:               1,
+		LongTermFuelTrim:         130,
+		JackCount:                41,
+		Dataframe80:              "801C",
+	}
+}
+
+func TestMemsRoscoMapping(t *testing.T) {
+	data := getMemsRoscoData().ToMemsFCRData()
+
+	then.AssertThat(t, data.Time, is.EqualTo("18:49:07"))
+	then.AssertThat(t, data.EngineRPM, is.EqualTo(850))
+	then.AssertThat(t, data.CoolantTemp, is.EqualTo(88))
+	then.AssertThat(t, data.BatteryVoltage, is.EqualTo(float32(14.1)))
+	then.AssertThat(t, data.IdleSwitch, is.True())
+	then.AssertThat(t, data.AirconSwitch, is.False())
+	then.AssertThat(t, data.ParkNeutralSwitch, is.True())
+	then.AssertThat(t, data.DTC0, is.EqualTo(uint8(2)))
+	then.AssertThat(t, data.IdleSpeedDeviation, is.EqualTo(1500))
+	then.AssertThat(t, data.CrankshaftPositionSensor, is.True())
+	then.AssertThat(t, data.IgnitionSwitch, is.True())
+	then.AssertThat(t, data.ClosedLoop, is.True())
+	then.AssertThat(t, data.LongTermFuelTrim, is.EqualTo(130))
+	then.AssertThat(t, data.JackCount, is.EqualTo(41))
+	then.AssertThat(t, data.Dataframe80, is.EqualTo("801C"))
+}
+
+func TestMemsRoscoV2Mapping(t *testing.T) {
+	v2 := scenarios.MemsRoscoV2Data(*getMemsRoscoData())
+	data := v2.ToMemsFCRData()
+
+	then.AssertThat(t, *data, is.EqualTo(*getMemsRoscoData().ToMemsFCRData()))
+}
+
+func TestMemsFCRRawMapping(t *testing.T) {
+	raw := &scenarios.MemsFCRRawData{Time: "20:12:11.467", EngineRPM: 900, IdleSwitch: true, DTC5: 4, Dataframe7d: "7D20"}
+	data := raw.ToMemsFCRData()
+
+	then.AssertThat(t, data.EngineRPM, is.EqualTo(900))
+	then.AssertThat(t, data.IdleSwitch, is.True())
+	then.AssertThat(t, data.DTC5, is.EqualTo(uint8(4)))
+	then.AssertThat(t, data.Dataframe7d, is.EqualTo("7D20"))
+}
+
+// BenchmarkMemsRoscoJSONCopy is the json round trip the typed mapping replaced
+func BenchmarkMemsRoscoJSONCopy(b *testing.B) {
+	data := getMemsRoscoData()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		memsdata := &scenarios.MemsFCRData{}
+		j, _ := json.Marshal(data)
+		_ = json.Unmarshal(j, memsdata)
+	}
+}
+
+func BenchmarkMemsRoscoMapping(b *testing.B) {
+	data := getMemsRoscoData()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		_ = data.ToMemsFCRData()
+	}
+}
@@ -1,11 +1,17 @@
 package tests
 
 import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
 	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+	"github.com/corbym/gocrest/has"
 	"github.com/corbym/gocrest/is"
 	"github.com/corbym/gocrest/then"
-	"path/filepath"
-	"testing"
 )
 
 func getFilePath(filename string) string {
@@ -17,4 +23,64 @@ func TestReadMemsData(t *testing.T) {
 	r := scenarios.NewReadMems()
 	scenario := r.Convert(file)
 	then.AssertThat(t, scenario.Count, is.GreaterThan(0))
+
+	// the log has no timestamps so each dataframe has a distinct time
+	elapsed := scenario.Elapsed()
+	for i := 1; i < len(elapsed); i++ {
+		then.AssertThat(t, elapsed[i], is.GreaterThan(elapsed[i-1]))
+	}
+}
+
+func TestReadMemsPairsFrames(t *testing.T) {
+	df80 := "80: 1C 00 00 3C FF 3E FF 63 72 6B 08 10 01 00 00 00 19 90 93 04 E2 00 38 0E 01 10 00 00 "
+	df7d := "7D: 20 10 4F FF 7D 00 3C FF FF 01 00 95 64 00 FF 6B FF FF 30 80 7B 99 FF 19 40 1F 00 26 40 34 C0 15 "
+
+	log := strings.Join([]string{
+		"Running command: read-raw",
+		"ECU responded to D0 command with: 99 00 02 03",
+		"",
+		df7d,                             // 7d before any 80, dropped
+		df80,                             // 80 without a 7d, dropped
+		df80,                             // paired
+		"D0: 99 00 02 03",                // other command responses don't break the pairing
+		df7d,                             // paired
+		df80,                             // followed by a short 7d, dropped
+		"7D: 20 10 4F FF 7D 00 3C FF",    // short frame
+		"80: 1C 00 00 3C FF 3E ZZ 63 72", // invalid byte
+		"CC: 00",
+		df80, // paired
+		df7d, // paired
+		df80, // no 7d at the end of the log, dropped
+	}, "\n")
+
+	reader, err := scenarios.NewFrameReader(strings.NewReader(log), utils.ReadMemsFile)
+	then.AssertThat(t, err, is.Nil())
+
+	scenario, err := scenarios.ReadAll(reader)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, scenario.Count, is.EqualTo(2))
+	then.AssertThat(t, scenario.Memsdata[0].CoolantTemp, is.EqualTo(5))
+
+	// the times follow the 0x80 requests from 00:00:00, including the dropped and corrupted requests
+	then.AssertThat(t, scenario.Memsdata[0].Time, is.EqualTo("00:00:00.100"))
+	then.AssertThat(t, scenario.Memsdata[1].Time, is.EqualTo("00:00:00.400"))
+
+	report := reader.(scenarios.ParseReporter).Report()
+	then.AssertThat(t, report.Frames, is.EqualTo(2))
+	then.AssertThat(t, report.Dropped, is.EqualTo(4))
+	then.AssertThat(t, report.Corrupted, is.EqualTo(2))
+	then.AssertThat(t, report.Skipped, is.EqualTo(4))
+	then.AssertThat(t, report.Problems, has.Length(6))
+
+	_, err = reader.Read()
+	then.AssertThat(t, err, is.EqualTo(io.EOF))
+
+	// the interval is an assumption that can be changed for logs read at a different rate
+	interval := scenarios.ReadMemsInterval
+	scenarios.ReadMemsInterval = time.Second
+	defer func() { scenarios.ReadMemsInterval = interval }()
+
+	reader, _ = scenarios.NewFrameReader(strings.NewReader(log), utils.ReadMemsFile)
+	scenario, _ = scenarios.ReadAll(reader)
+	then.AssertThat(t, scenario.Elapsed()[1], is.EqualTo(3*time.Second))
 }
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrewdjackson/memscene/rules"
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/corbym/gocrest/has"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+)
+
+func TestRulesMatchForDuration(t *testing.T) {
+	scenario := scenarios.NewScenario()
+
+	// iac high from 10:00:05 to 10:00:19
+	for i := 0; i < 30; i++ {
+		iac := 40
+		if i >= 5 && i < 20 {
+			iac = 70
+		}
+
+		scenario.Memsdata = append(scenario.Memsdata, &scenarios.MemsFCRData{Time: fmt.Sprintf("10:00:%02d", i), CoolantTemp: 85, IACPosition: iac})
+	}
+
+	rule, err := rules.NewRule("warm-high-iac", rules.Warning, "CoolantTemp > 80 && IACPosition > 60 for 10s")
+	then.AssertThat(t, err, is.Nil())
+
+	short, _ := rules.NewRule("short", rules.Info, "IACPosition > 60 for 20s")
+
+	report, err := rules.Evaluate(scenario, []*rules.Rule{rule, short})
+	then.AssertThat(t, err, is.Nil())
+
+	then.AssertThat(t, report.Findings[0].Matches, has.Length(1))
+	then.AssertThat(t, report.Findings[0].Matches[0].Start, is.EqualTo("10:00:05"))
+	then.AssertThat(t, report.Findings[0].Matches[0].End, is.EqualTo("10:00:19"))
+	then.AssertThat(t, report.Findings[1].Matches, has.Length(0))
+}
+
+func TestRulesLoadFile(t *testing.T) {
+	r, err := rules.LoadRules(getFilePath("../data/rules.csv"))
+
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, len(r), is.GreaterThan(0))
+}
+
+func TestRulesDurationIncludesTheLastSample(t *testing.T) {
+	scenario := scenarios.NewScenario()
+
+	// iac high for 10 dataframes at 1Hz, from 10:00:05 until 10:00:15
+	for i := 0; i < 20; i++ {
+		iac := 40
+		if i >= 5 && i < 15 {
+			iac = 70
+		}
+
+		scenario.Memsdata = append(scenario.Memsdata, &scenarios.MemsFCRData{Time: fmt.Sprintf("10:00:%02d", i), IACPosition: iac})
+	}
+
+	rule, _ := rules.NewRule("high-iac", rules.Warning, "IACPosition > 60 for 10s")
+	longer, _ := rules.NewRule("longer", rules.Warning, "IACPosition > 60 for 11s")
+	always, _ := rules.NewRule("always", rules.Info, "IACPosition > 0 for 20s")
+
+	report, err := rules.Evaluate(scenario, []*rules.Rule{rule, longer, always})
+	then.AssertThat(t, err, is.Nil())
+
+	then.AssertThat(t, report.Findings[0].Matches, has.Length(1))
+	then.AssertThat(t, report.Findings[0].Matches[0].Duration, is.EqualTo(10*time.Second))
+	then.AssertThat(t, report.Findings[1].Matches, has.Length(0))
+
+	// the last dataframe counts for the mean interval
+	then.AssertThat(t, report.Findings[2].Matches, has.Length(1))
+	then.AssertThat(t, report.Findings[2].Matches[0].Duration, is.EqualTo(20*time.Second))
+}
+
+func TestRulesOperators(t *testing.T) {
+	data := &scenarios.MemsFCRData{ClosedLoop: true, IACPosition: 30}
+
+	expression, err := rules.Compile("!!ClosedLoop && IACPosition >= 30")
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, expression.Match(data), is.True())
+
+	for _, condition := range []string{"IACPosition &= 1", "IACPosition |= 1", "IACPosition => 1", "IACPosition & 1", "IACPosition = 30"} {
+		_, err := rules.Compile(condition)
+		then.AssertThat(t, err, is.Not(is.Nil()))
+	}
+}
+
+func TestRulesSeverity(t *testing.T) {
+	_, err := rules.NewRule("critical", "critical", "IACPosition > 60")
+	then.AssertThat(t, err, is.Not(is.Nil()))
+
+	file := filepath.Join(t.TempDir(), "rules.csv")
+	os.WriteFile(file, []byte("name,severity,condition,description\nhigh-iac, Warning ,IACPosition > 60,iac high\nlow-iac,severe,IACPosition < 5,iac low\n"), 0644)
+
+	_, err = rules.LoadRules(file)
+	then.AssertThat(t, err, is.Not(is.Nil()))
+	then.AssertThat(t, err.Error(), is.ValueContaining("severe"))
+}
+
+func TestRulesDoNotMatchMissingChannels(t *testing.T) {
+	d80, d7d := readmemsFrames(t)
+
+	// 0x7d frame without the last 6 bytes, the jack count is missing
+	short7d := append([]byte{}, d7d[:27]...)
+	short7d[1] = 0x1A
+
+	scenario := scenarios.NewScenario()
+
+	for i := 0; i < 11; i++ {
+		frame7d := d7d
+		if i == 5 {
+			frame7d = short7d
+		}
+
+		data, _ := scenarios.FindVariant("", d80, frame7d).Decode(fmt.Sprintf("10:00:%02d", i), d80, frame7d)
+		scenario.Memsdata = append(scenario.Memsdata, data)
+	}
+
+	scenario.Count = len(scenario.Memsdata)
+
+	rule, _ := rules.NewRule("jack-count", rules.Info, "JackCount < 256 for 5s")
+	either, _ := rules.NewRule("either", rules.Info, "JackCount < 256 || CoolantTemp > -100 for 6s")
+
+	report, err := rules.Evaluate(scenario, []*rules.Rule{rule, either})
+	then.AssertThat(t, err, is.Nil())
+
+	// the missing channel breaks the duration
+	then.AssertThat(t, report.Findings[0].Matches, has.Length(2))
+	then.AssertThat(t, report.Findings[0].Matches[0].End, is.EqualTo("10:00:04"))
+	then.AssertThat(t, report.Findings[0].Matches[1].Start, is.EqualTo("10:00:06"))
+	then.AssertThat(t, report.Findings[1].Matches, has.Length(0))
+}
+
+func TestRulesEvaluateLiteralRules(t *testing.T) {
+	scenario := scenarios.NewScenario()
+	scenario.Memsdata = []*scenarios.MemsFCRData{{Time: "10:00:00", IACPosition: 70}}
+	scenario.Count = 1
+
+	rule := &rules.Rule{Name: "high-iac", Severity: rules.Warning, Condition: "IACPosition > 60"}
+
+	report, err := rules.Evaluate(scenario, []*rules.Rule{rule})
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, report.Findings[0].Matches, has.Length(1))
+
+	invalid := &rules.Rule{Name: "invalid", Severity: rules.Warning, Condition: "IACPosition >"}
+
+	_, err = rules.Evaluate(scenario, []*rules.Rule{invalid})
+	then.AssertThat(t, err, is.Not(is.Nil()))
+}
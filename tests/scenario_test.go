@@ -0,0 +1,335 @@
+package tests
+
+import (
+	"context"
+	"encoding/csv"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+	"github.com/corbym/gocrest/has"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+)
+
+func TestScenarioDiff(t *testing.T) {
+	a := scenarios.NewScenario()
+	b := scenarios.NewScenario()
+
+	a.Memsdata = []*scenarios.MemsFCRData{
+		{Time: "10:00:00", CoolantTemp: 80, Dataframe80: "801c0000"},
+		{Time: "10:00:01", CoolantTemp: 81, Dataframe80: "801c0000"},
+	}
+	b.Memsdata = []*scenarios.MemsFCRData{
+		{Time: "10:00:01", CoolantTemp: 85, Dataframe80: "801C0001"},
+	}
+
+	diff := a.Diff(b, scenarios.MatchByTime)
+
+	then.AssertThat(t, diff.Matched, is.EqualTo(1))
+	then.AssertThat(t, diff.Unmatched, is.EqualTo(1))
+	then.AssertThat(t, diff.Fields, has.Length(1))
+	then.AssertThat(t, diff.Fields[0].MaxDifference, is.EqualTo(4.0))
+	then.AssertThat(t, diff.Bytes, has.Length(1))
+	then.AssertThat(t, diff.Bytes[0].Offset, is.EqualTo(3))
+}
+
+func TestScenarioDiffDecodedColumns(t *testing.T) {
+	file, err := os.Open("../data/memsfcr.csv")
+	then.AssertThat(t, err, is.Nil())
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	then.AssertThat(t, err, is.Nil())
+
+	// change only the decoded coolant temperature, the raw dataframes are unchanged
+	for _, record := range records[1:] {
+		coolant, _ := strconv.Atoi(record[2])
+		record[2] = strconv.Itoa(coolant + 30)
+	}
+
+	changed := filepath.Join(t.TempDir(), "changed.csv")
+	out, err := os.Create(changed)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, csv.NewWriter(out).WriteAll(records), is.Nil())
+	out.Close()
+
+	a, err := scenarios.LoadFile("../data/memsfcr.csv")
+	then.AssertThat(t, err, is.Nil())
+	b, err := scenarios.LoadFile(changed)
+	then.AssertThat(t, err, is.Nil())
+
+	diff := a.Diff(b, scenarios.MatchByIndex)
+
+	then.AssertThat(t, diff.Matched, is.EqualTo(a.Count))
+	then.AssertThat(t, diff.Fields, has.Length(1))
+	then.AssertThat(t, diff.Fields[0].Field, is.EqualTo("CoolantTemp"))
+	then.AssertThat(t, diff.Fields[0].Frames, is.EqualTo(a.Count))
+	then.AssertThat(t, diff.Fields[0].MaxDifference, is.EqualTo(30.0))
+	then.AssertThat(t, diff.Bytes, has.Length(0))
+}
+
+func TestScenarioPlaybackCursor(t *testing.T) {
+	scenario := scenarios.NewScenario()
+	scenario.Memsdata = []*scenarios.MemsFCRData{
+		{Time: "10:00:00", EngineRPM: 0},
+		{Time: "10:00:01", EngineRPM: 1},
+		{Time: "10:00:02", EngineRPM: 2},
+	}
+
+	data, _ := scenario.Next()
+	then.AssertThat(t, data.EngineRPM, is.EqualTo(0))
+	data, _ = scenario.Next()
+	then.AssertThat(t, data.EngineRPM, is.EqualTo(1))
+	data, _ = scenario.Prev()
+	then.AssertThat(t, data.EngineRPM, is.EqualTo(0))
+
+	then.AssertThat(t, scenario.Seek(1500*time.Millisecond), is.True())
+	data, _ = scenario.Peek()
+	then.AssertThat(t, data.EngineRPM, is.EqualTo(2))
+	scenario.Next()
+
+	_, ok := scenario.Next()
+	then.AssertThat(t, ok, is.False())
+
+	scenario.SetLoop(true)
+	data, ok = scenario.Next()
+	then.AssertThat(t, ok, is.True())
+	then.AssertThat(t, data.EngineRPM, is.EqualTo(0))
+}
+
+func TestScenarioPlay(t *testing.T) {
+	scenario := scenarios.NewScenario()
+	scenario.Memsdata = []*scenarios.MemsFCRData{
+		{Time: "10:00:00"},
+		{Time: "10:00:01"},
+		{Time: "10:00:02"},
+	}
+	scenario.SetSpeed(100)
+
+	frames := 0
+	for range scenario.Play(context.Background()) {
+		frames++
+	}
+
+	then.AssertThat(t, frames, is.EqualTo(3))
+}
+
+func TestScenarioPlayResumesFromTheUnsentDataframe(t *testing.T) {
+	scenario := scenarios.NewScenario()
+	scenario.Memsdata = []*scenarios.MemsFCRData{
+		{Time: "10:00:00", EngineRPM: 0},
+		{Time: "10:00:01", EngineRPM: 1},
+		{Time: "10:00:02", EngineRPM: 2},
+	}
+	scenario.SetSpeed(100)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	frames := scenario.Play(ctx)
+	first := <-frames
+
+	// pause while the next dataframe is waiting to be sent
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	for range frames {
+	}
+
+	data, _ := scenario.Next()
+	then.AssertThat(t, first.EngineRPM, is.EqualTo(0))
+	then.AssertThat(t, data.EngineRPM, is.EqualTo(1))
+}
+
+func TestScenarioSeekAfterDataframesReplaced(t *testing.T) {
+	scenario := scenarios.NewScenario()
+	scenario.Memsdata = []*scenarios.MemsFCRData{{Time: "10:00:00"}, {Time: "10:00:01"}, {Time: "10:00:02"}}
+	then.AssertThat(t, scenario.Seek(2*time.Second), is.True())
+
+	// same number of dataframes at a higher sample rate
+	scenario.Memsdata = []*scenarios.MemsFCRData{{Time: "10:00:00.000"}, {Time: "10:00:00.500"}, {Time: "10:00:01.000"}}
+	then.AssertThat(t, scenario.Seek(2*time.Second), is.False())
+	then.AssertThat(t, scenario.Seek(time.Second), is.True())
+	then.AssertThat(t, scenario.GetPosition(), is.EqualTo(2))
+}
+
+func TestScenarioInjectFaults(t *testing.T) {
+	r := scenarios.NewMemsFCR()
+	scenario := r.Convert(getFilePath("../data/memsfcr.csv"))
+
+	injections := []*scenarios.Injection{
+		{Fault: scenarios.DTCFault, Channel: "coolant"},
+		{Fault: scenarios.BatterySagFault, Value: 1.5},
+		{Fault: scenarios.OpenCircuitFault, Channel: "throttlepot"},
+	}
+
+	injected, err := scenario.Inject(injections)
+
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, injected.Count, is.EqualTo(scenario.Count))
+	then.AssertThat(t, injected.Memsdata[0].DTC0&scenarios.CoolantSensorFaultCode, is.EqualTo(scenarios.CoolantSensorFaultCode))
+	then.AssertThat(t, injected.Memsdata[0].BatteryVoltage, is.EqualTo(float32(10.6)))
+	then.AssertThat(t, injected.Memsdata[0].ThrottlePotSensor, is.EqualTo(float32(0)))
+
+	// the original scenario is unchanged
+	then.AssertThat(t, scenario.Memsdata[0].BatteryVoltage, is.EqualTo(float32(12.1)))
+}
+
+func TestScenarioInjectDropoutProbability(t *testing.T) {
+	r := scenarios.NewMemsFCR()
+	scenario := r.Convert(getFilePath("../data/memsfcr.csv"))
+
+	for _, probability := range []float64{0, -0.5, 1.5, math.NaN()} {
+		_, err := scenario.Inject([]*scenarios.Injection{{Fault: scenarios.DropoutFault, Channel: "coolant", Probability: probability}})
+		then.AssertThat(t, err, is.Not(is.Nil()))
+	}
+
+	_, err := scenario.Inject([]*scenarios.Injection{{Fault: scenarios.DropoutFault, Channel: "coolant", Probability: 1}})
+	then.AssertThat(t, err, is.Nil())
+}
+
+func TestScenarioInjectOnlyChangesFaultyChannels(t *testing.T) {
+	for _, file := range []string{"../logfiles/nofaults-warm.csv", "../data/memsrosco.txt"} {
+		scenario, err := scenarios.ConvertFile(file)
+		then.AssertThat(t, err, is.Nil())
+
+		injection := &scenarios.Injection{Fault: "DTC", Channel: " Coolant"}
+
+		injected, err := scenario.Inject([]*scenarios.Injection{injection})
+		then.AssertThat(t, err, is.Nil())
+
+		// the caller's injection is unchanged
+		then.AssertThat(t, injection.Fault, is.EqualTo("DTC"))
+		then.AssertThat(t, injection.Channel, is.EqualTo(" Coolant"))
+
+		for i, data := range injected.Memsdata {
+			then.AssertThat(t, data.DTC0&scenarios.CoolantSensorFaultCode, is.EqualTo(scenarios.CoolantSensorFaultCode))
+
+			// clear the fault to compare the other channels with the clean log
+			faulty := *data
+			faulty.DTC0 = scenario.Memsdata[i].DTC0
+			faulty.Dataframe80 = scenario.Memsdata[i].Dataframe80
+
+			then.AssertThat(t, faulty, is.EqualTo(*scenario.Memsdata[i]))
+		}
+	}
+}
+
+func TestScenarioConvertDirectory(t *testing.T) {
+	out := t.TempDir()
+
+	report, err := scenarios.ConvertDirectory("../data", out, 4)
+	then.AssertThat(t, err, is.Nil())
+
+	then.AssertThat(t, report.Count(scenarios.Converted), is.GreaterThan(0))
+	then.AssertThat(t, report.Count(scenarios.Skipped), is.GreaterThan(0))
+
+	// memscene convert exits 1 when a file fails
+	then.AssertThat(t, report.Count(scenarios.Failed), is.EqualTo(0))
+
+	for _, result := range report.Results {
+		if result.Status == scenarios.Converted {
+			_, err := os.Stat(result.Destination)
+			then.AssertThat(t, err, is.Nil())
+		}
+	}
+
+	// memsdiag logs are recognised but can't be converted
+	memsdiag := batchResult(report, filepath.Join("..", "data", "memsdiag.txt"))
+	then.AssertThat(t, memsdiag, is.Not(is.Nil()))
+	then.AssertThat(t, memsdiag.Status, is.EqualTo(scenarios.Skipped))
+	then.AssertThat(t, memsdiag.Reason, is.ValueContaining("memsdiag"))
+	then.AssertThat(t, report.String(), is.ValueContaining("skipped: memsdiag logs"))
+}
+
+func TestScenarioConvertDirectoryWithSpacedNames(t *testing.T) {
+	dir := t.TempDir()
+	name := "nearly ideal _high IAC.txt"
+
+	data, err := os.ReadFile(filepath.Join("..", "logfiles", name))
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, os.MkdirAll(filepath.Join(dir, "road tests"), os.ModePerm), is.Nil())
+	then.AssertThat(t, os.WriteFile(filepath.Join(dir, "road tests", name), data, 0644), is.Nil())
+
+	out := filepath.Join(dir, "converted")
+	report, err := scenarios.ConvertDirectory(dir, out, 2)
+	then.AssertThat(t, err, is.Nil())
+
+	result := batchResult(report, filepath.Join(dir, "road tests", name))
+	then.AssertThat(t, result.Status, is.EqualTo(scenarios.Converted))
+	then.AssertThat(t, result.Count, is.GreaterThan(0))
+	then.AssertThat(t, result.Destination, is.EqualTo(filepath.Join(out, "road tests", name+".output.csv")))
+
+	// converting again doesn't convert the output inside the directory
+	report, err = scenarios.ConvertDirectory(dir, out, 2)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, report.Results, has.Length(1))
+
+	// or the converted files written alongside the logs
+	scenarios.ConvertDirectory(dir, dir, 2)
+	report, err = scenarios.ConvertDirectory(dir, dir, 2)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, report.Results, has.Length(1))
+}
+
+// batchResult returns the result for the source file
+func batchResult(report *scenarios.BatchReport, source string) *scenarios.BatchResult {
+	for _, result := range report.Results {
+		if result.Source == source {
+			return result
+		}
+	}
+
+	return nil
+}
+
+func TestTimestampToDuration(t *testing.T) {
+	tests := []struct {
+		timestamp string
+		duration  time.Duration
+	}{
+		{"00:00:00", 0},
+		{"08:15:30", 8*time.Hour + 15*time.Minute + 30*time.Second},
+		{"08:15:30.250", 8*time.Hour + 15*time.Minute + 30*time.Second + 250*time.Millisecond},
+		{"23:59:59.999", 24*time.Hour - time.Millisecond},
+		{"15:30", 15*time.Minute + 30*time.Second},
+	}
+
+	for _, test := range tests {
+		duration, err := utils.TimestampToDuration(test.timestamp)
+		then.AssertThat(t, err, is.Nil())
+		then.AssertThat(t, duration, is.EqualTo(test.duration))
+	}
+
+	for _, timestamp := range []string{"", "8am", "25:00:00"} {
+		_, err := utils.TimestampToDuration(timestamp)
+		then.AssertThat(t, err, is.Not(is.Nil()))
+	}
+}
+
+func TestScenarioElapsed(t *testing.T) {
+	tests := []struct {
+		times   []string
+		elapsed []time.Duration
+	}{
+		{[]string{"08:00:00", "08:00:01", "08:00:03"}, []time.Duration{0, time.Second, 3 * time.Second}},
+		{[]string{"08:00:00.000", "08:00:00.250", "08:00:01.500"}, []time.Duration{0, 250 * time.Millisecond, 1500 * time.Millisecond}},
+		// the log runs past midnight
+		{[]string{"23:59:59", "00:00:00.500", "00:00:01"}, []time.Duration{0, 1500 * time.Millisecond, 2 * time.Second}},
+		// unreadable times are a second after the previous dataframe
+		{[]string{"08:00:00", "", "08:00:02"}, []time.Duration{0, time.Second, 2 * time.Second}},
+	}
+
+	for _, test := range tests {
+		scenario := scenarios.NewScenario()
+
+		for _, time := range test.times {
+			scenario.Memsdata = append(scenario.Memsdata, &scenarios.MemsFCRData{Time: time})
+		}
+
+		then.AssertThat(t, scenario.Elapsed(), is.EqualTo(test.elapsed))
+	}
+}
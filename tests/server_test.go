@@ -0,0 +1,289 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andrewdjackson/memscene/analysers"
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/server"
+	"github.com/corbym/gocrest/has"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"github.com/gorilla/websocket"
+)
+
+func TestReplayServerStream(t *testing.T) {
+	scenario := scenarios.NewScenario()
+	scenario.Memsdata = []*scenarios.MemsFCRData{
+		{Time: "10:00:00", EngineRPM: 800},
+		{Time: "10:00:01", EngineRPM: 810},
+		{Time: "10:00:02", EngineRPM: 820},
+		{Time: "10:00:03", EngineRPM: 830},
+	}
+	scenario.Count = len(scenario.Memsdata)
+
+	s := httptest.NewServer(server.NewReplayServer(scenario).Handler())
+	defer s.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(s.URL, "http")+"/stream", nil)
+	then.AssertThat(t, err, is.Nil())
+	defer conn.Close()
+
+	var data scenarios.MemsFCRData
+	then.AssertThat(t, conn.ReadJSON(&data), is.Nil())
+	then.AssertThat(t, data.Time, is.EqualTo("10:00:00"))
+
+	// pause, seek to the last frame and play at high speed
+	then.AssertThat(t, conn.WriteJSON(server.Control{Action: server.PauseAction}), is.Nil())
+	then.AssertThat(t, readStatus(t, conn).Status, is.EqualTo("paused"))
+
+	then.AssertThat(t, conn.WriteJSON(server.Control{Action: server.SeekAction, Time: "3s"}), is.Nil())
+	status := readStatus(t, conn)
+	then.AssertThat(t, status.Position, is.EqualTo(3))
+	then.AssertThat(t, status.Error, is.EqualTo(""))
+
+	then.AssertThat(t, conn.WriteJSON(server.Control{Action: server.SpeedAction, Speed: -1}), is.Nil())
+	status = readStatus(t, conn)
+	then.AssertThat(t, status.Error, is.ValueContaining("invalid playback speed"))
+	then.AssertThat(t, status.Speed, is.EqualTo(1.0))
+
+	then.AssertThat(t, conn.WriteJSON(server.Control{Action: server.SpeedAction, Speed: 100}), is.Nil())
+	then.AssertThat(t, readStatus(t, conn).Speed, is.EqualTo(100.0))
+
+	then.AssertThat(t, conn.WriteJSON(server.Control{Action: server.PlayAction}), is.Nil())
+	then.AssertThat(t, readStatus(t, conn).Status, is.EqualTo("playing"))
+
+	then.AssertThat(t, conn.ReadJSON(&data), is.Nil())
+	then.AssertThat(t, data.EngineRPM, is.EqualTo(830))
+	then.AssertThat(t, readStatus(t, conn).Status, is.EqualTo("ended"))
+}
+
+func TestReplayServerOrigins(t *testing.T) {
+	scenario := scenarios.NewScenario()
+	scenario.Memsdata = []*scenarios.MemsFCRData{{Time: "10:00:00"}}
+	scenario.Count = 1
+
+	replay := server.NewReplayServer(scenario)
+	replay.AllowedOrigins = []string{"http://localhost:3000"}
+
+	s := httptest.NewServer(replay.Handler())
+	defer s.Close()
+
+	stream := "ws" + strings.TrimPrefix(s.URL, "http") + "/stream"
+
+	for origin, allowed := range map[string]bool{
+		"":                      true,
+		s.URL:                   true,
+		"http://localhost:3000": true,
+		"http://example.com":    false,
+	} {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+
+		conn, _, err := websocket.DefaultDialer.Dial(stream, header)
+		then.AssertThat(t, err == nil, is.EqualTo(allowed))
+
+		if conn != nil {
+			conn.Close()
+		}
+	}
+}
+
+// readStatus skips any frames sent before the status message
+func readStatus(t *testing.T, conn *websocket.Conn) server.Status {
+	for {
+		var status server.Status
+		then.AssertThat(t, conn.ReadJSON(&status), is.Nil())
+
+		if status.Status != "" {
+			return status
+		}
+	}
+}
+
+func TestAPIServerUploadAndAnalyse(t *testing.T) {
+	s := httptest.NewServer(server.NewAPIServer().Handler())
+	defer s.Close()
+
+	file, err := os.Open("../data/memsfcr.csv")
+	then.AssertThat(t, err, is.Nil())
+	defer file.Close()
+
+	response, err := http.Post(s.URL+"/api/logs?name=memsfcr.csv", "text/csv", file)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, response.StatusCode, is.EqualTo(http.StatusCreated))
+
+	var uploaded server.Log
+	then.AssertThat(t, json.NewDecoder(response.Body).Decode(&uploaded), is.Nil())
+	then.AssertThat(t, uploaded.FileType, is.EqualTo("memsfcr"))
+	then.AssertThat(t, uploaded.Count, is.GreaterThan(0))
+
+	logURL := fmt.Sprintf("%s/api/logs/%d", s.URL, uploaded.ID)
+
+	var stats scenarios.Stats
+	getJSON(t, logURL+"/stats", &stats)
+	then.AssertThat(t, stats.Count, is.EqualTo(uploaded.Count))
+
+	// the reports are the analysers' report structures
+	var reports []struct {
+		Name   string
+		Report analysers.FuelTrimReport
+	}
+	getJSON(t, logURL+"/analysers?name=fueltrim", &reports)
+	then.AssertThat(t, reports, has.Length(1))
+	then.AssertThat(t, reports[0].Name, is.EqualTo("fueltrim"))
+	then.AssertThat(t, reports[0].Report.Diagnosis, is.Not(is.EqualTo("")))
+
+	var data []scenarios.MemsFCRData
+	getJSON(t, logURL+"/download?format=json", &data)
+	then.AssertThat(t, data, has.Length(uploaded.Count))
+
+	response, err = http.Get(s.URL + "/api/logs/99/stats")
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, response.StatusCode, is.EqualTo(http.StatusNotFound))
+}
+
+func TestAPIServerRejectsLogsWithoutDataframes(t *testing.T) {
+	s := httptest.NewServer(server.NewAPIServer().Handler())
+	defer s.Close()
+
+	response, err := http.Post(s.URL+"/api/logs?name=garbage.txt", "text/plain", strings.NewReader("not a log\nat all\n"))
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, response.StatusCode, is.EqualTo(http.StatusUnprocessableEntity))
+
+	var logs []server.Log
+	getJSON(t, s.URL+"/api/logs", &logs)
+	then.AssertThat(t, logs, has.Length(0))
+}
+
+func TestAPIServerRejectsTruncatedLogs(t *testing.T) {
+	s := httptest.NewServer(server.NewAPIServer().Handler())
+	defer s.Close()
+
+	log, err := os.ReadFile("../data/memsfcr.csv")
+	then.AssertThat(t, err, is.Nil())
+
+	// the last record is cut short
+	lines := strings.Split(strings.TrimSpace(string(log)), "\n")
+	last := lines[len(lines)-1]
+	lines[len(lines)-1] = last[:strings.Index(last, ",")+1]
+	truncated := []byte(strings.Join(lines, "\n"))
+
+	response, err := http.Post(s.URL+"/api/logs?name=truncated.csv", "text/csv", bytes.NewReader(truncated))
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, response.StatusCode, is.EqualTo(http.StatusUnprocessableEntity))
+
+	// the dataframes read before the error are returned with it
+	file := filepath.Join(t.TempDir(), "truncated.csv")
+	then.AssertThat(t, os.WriteFile(file, truncated, 0644), is.Nil())
+
+	scenario, err := scenarios.ConvertFile(file)
+	then.AssertThat(t, err, is.Not(is.Nil()))
+	then.AssertThat(t, scenario.Count, is.GreaterThan(0))
+}
+
+func TestAPIServerDeleteAndEvictLogs(t *testing.T) {
+	api := server.NewAPIServer()
+	api.MaxLogs = 2
+	s := httptest.NewServer(api.Handler())
+	defer s.Close()
+
+	first := uploadLog(t, s.URL, "first.csv")
+	second := uploadLog(t, s.URL, "second.csv")
+
+	// using the first log makes the second the least recently used
+	var details server.Log
+	getJSON(t, fmt.Sprintf("%s/api/logs/%d", s.URL, first.ID), &details)
+
+	third := uploadLog(t, s.URL, "third.csv")
+
+	var logs []server.Log
+	getJSON(t, s.URL+"/api/logs", &logs)
+	then.AssertThat(t, logs, has.Length(2))
+	then.AssertThat(t, logs[0].ID, is.EqualTo(first.ID))
+	then.AssertThat(t, logs[1].ID, is.EqualTo(third.ID))
+
+	request, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/api/logs/%d", s.URL, first.ID), nil)
+	response, err := http.DefaultClient.Do(request)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, response.StatusCode, is.EqualTo(http.StatusNoContent))
+
+	for _, id := range []int{first.ID, second.ID} {
+		response, err = http.Get(fmt.Sprintf("%s/api/logs/%d", s.URL, id))
+		then.AssertThat(t, err, is.Nil())
+		then.AssertThat(t, response.StatusCode, is.EqualTo(http.StatusNotFound))
+	}
+
+	// the size limit keeps only the latest log
+	api.MaxSize = third.Size
+	uploadLog(t, s.URL, "fourth.csv")
+	getJSON(t, s.URL+"/api/logs", &logs)
+	then.AssertThat(t, logs, has.Length(1))
+}
+
+func TestAPIServerCountsTheDecompressedSize(t *testing.T) {
+	s := httptest.NewServer(server.NewAPIServer().Handler())
+	defer s.Close()
+
+	for _, name := range []string{"memsrosco.txt", "memsrosco.txt.bz2"} {
+		file, err := os.Open(filepath.Join("..", "data", name))
+		then.AssertThat(t, err, is.Nil())
+
+		response, err := http.Post(s.URL+"/api/logs?name="+name, "text/plain", file)
+		file.Close()
+		then.AssertThat(t, err, is.Nil())
+		then.AssertThat(t, response.StatusCode, is.EqualTo(http.StatusCreated))
+
+		var uploaded server.Log
+		then.AssertThat(t, json.NewDecoder(response.Body).Decode(&uploaded), is.Nil())
+
+		info, _ := os.Stat("../data/memsrosco.txt")
+		then.AssertThat(t, uploaded.Size, is.EqualTo(info.Size()))
+	}
+}
+
+func TestAPIServerDownloadName(t *testing.T) {
+	s := httptest.NewServer(server.NewAPIServer().Handler())
+	defer s.Close()
+
+	uploaded := uploadLog(t, s.URL, "..%2Fdir%2Fmy%22log%22.csv%0D%0A")
+
+	response, err := http.Get(fmt.Sprintf("%s/api/logs/%d/download", s.URL, uploaded.ID))
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, response.Header.Get("Content-Disposition"), is.EqualTo(`attachment; filename=mylog.csv.csv`))
+}
+
+// uploadLog uploads the memsfcr log with the name
+func uploadLog(t *testing.T, url string, name string) *server.Log {
+	file, err := os.Open("../data/memsfcr.csv")
+	then.AssertThat(t, err, is.Nil())
+	defer file.Close()
+
+	response, err := http.Post(url+"/api/logs?name="+name, "text/csv", file)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, response.StatusCode, is.EqualTo(http.StatusCreated))
+
+	uploaded := &server.Log{}
+	then.AssertThat(t, json.NewDecoder(response.Body).Decode(uploaded), is.Nil())
+
+	return uploaded
+}
+
+func getJSON(t *testing.T, url string, value interface{}) {
+	response, err := http.Get(url)
+	then.AssertThat(t, err, is.Nil())
+	defer response.Body.Close()
+
+	then.AssertThat(t, response.StatusCode, is.EqualTo(http.StatusOK))
+	then.AssertThat(t, json.NewDecoder(response.Body).Decode(value), is.Nil())
+}
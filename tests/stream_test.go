@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrewdjackson/memscene/generator"
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/corbym/gocrest/has"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+)
+
+func TestStreamMatchesConvertFile(t *testing.T) {
+	file := "../data/memsroscov2.txt"
+
+	scenario, err := scenarios.ConvertFile(file)
+	then.AssertThat(t, err, is.Nil())
+
+	var loaded bytes.Buffer
+	then.AssertThat(t, scenario.Write(&loaded, scenarios.CSVFormat), is.Nil())
+
+	reader, closer, err := scenarios.OpenFrameReader(file)
+	then.AssertThat(t, err, is.Nil())
+	defer closer.Close()
+
+	var streamed bytes.Buffer
+	writer, _ := scenarios.NewFrameWriter(&streamed, scenarios.CSVFormat)
+
+	count, err := scenarios.Stream(reader, writer)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, count, is.EqualTo(scenario.Count))
+	then.AssertThat(t, streamed.String(), is.EqualTo(loaded.String()))
+}
+
+// createLongLog generates a multi-hour MemsFCR log to benchmark the conversion
+func createLongLog(b *testing.B, duration time.Duration) string {
+	g := generator.NewGenerator(1)
+	g.Profile = []generator.Segment{
+		{Kind: generator.Crank, Duration: 2 * time.Second},
+		{Kind: generator.Drive, Duration: duration},
+	}
+
+	scenario, err := g.Generate()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	path := filepath.Join(b.TempDir(), "long.csv")
+	if err := scenario.WriteFile(path, scenarios.CSVFormat); err != nil {
+		b.Fatal(err)
+	}
+
+	return path
+}
+
+// peakHeap samples the heap while the function runs and returns the peak heap in use
+func peakHeap(f func()) uint64 {
+	var peak uint64
+	var stats runtime.MemStats
+
+	runtime.GC()
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+
+	go func() {
+		defer close(sampled)
+
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapInuse > peak {
+				peak = stats.HeapInuse
+			}
+
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+
+	f()
+	close(done)
+	<-sampled
+
+	return peak
+}
+
+// BenchmarkConvertLoadAll loads the whole log before writing it
+func BenchmarkConvertLoadAll(b *testing.B) {
+	log := createLongLog(b, 4*time.Hour)
+	b.ResetTimer()
+
+	peak := peakHeap(func() {
+		for i := 0; i < b.N; i++ {
+			scenario, _ := scenarios.ConvertFile(log)
+			scenario.Write(io.Discard, scenarios.CSVFormat)
+		}
+	})
+
+	b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MB")
+}
+
+// BenchmarkConvertStream streams the log, the peak heap doesn't grow with the length of the log
+func BenchmarkConvertStream(b *testing.B) {
+	log := createLongLog(b, 4*time.Hour)
+	b.ResetTimer()
+
+	peak := peakHeap(func() {
+		for i := 0; i < b.N; i++ {
+			reader, closer, _ := scenarios.OpenFrameReader(log)
+			writer, _ := scenarios.NewFrameWriter(io.Discard, scenarios.CSVFormat)
+			scenarios.Stream(reader, writer)
+			closer.Close()
+		}
+	})
+
+	b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MB")
+}
+
+func TestStreamDetectsTypeFromStdin(t *testing.T) {
+	log, err := os.ReadFile("../data/readmems.data")
+	then.AssertThat(t, err, is.Nil())
+
+	// a pipe can't be reopened, detection must use the buffered prefix
+	in, out := io.Pipe()
+	go func() {
+		out.Write(log)
+		out.Close()
+	}()
+
+	reader, filetype, err := scenarios.DetectFrameReader(in)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, filetype, is.EqualTo("readmems"))
+
+	var ndjson bytes.Buffer
+	writer, _ := scenarios.NewFrameWriter(&ndjson, scenarios.NDJSONFormat)
+
+	count, err := scenarios.Stream(reader, writer)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, count, is.GreaterThan(0))
+
+	lines := strings.Split(strings.TrimSpace(ndjson.String()), "\n")
+	then.AssertThat(t, lines, has.Length(count))
+
+	var data scenarios.MemsFCRData
+	then.AssertThat(t, json.Unmarshal([]byte(lines[0]), &data), is.Nil())
+}
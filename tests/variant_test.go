@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+	"github.com/corbym/gocrest/has"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+)
+
+func TestDecodeShortDataframe(t *testing.T) {
+	d80, d7d := readmemsFrames(t)
+	standard := scenarios.DecodeDataframes("08:00:00.000", d80, d7d)
+
+	// 0x7d frame without the last 6 bytes
+	short7d := append([]byte{}, d7d[:27]...)
+	short7d[1] = 0x1A
+
+	data, missing := scenarios.FindVariant("", d80, short7d).Decode("08:00:00.000", d80, short7d)
+	then.AssertThat(t, missing, is.EqualTo([]string{"Uk7d1a", "Uk7d1b", "Uk7d1c", "Uk7d1d", "Uk7d1e", "JackCount"}))
+
+	then.AssertThat(t, data.LongTermFuelTrim, is.EqualTo(standard.LongTermFuelTrim))
+	then.AssertThat(t, data.IdleSpeedOffset, is.EqualTo(standard.IdleSpeedOffset))
+	then.AssertThat(t, data.CoolantTemp, is.EqualTo(standard.CoolantTemp))
+	then.AssertThat(t, data.JackCount, is.EqualTo(0))
+	then.AssertThat(t, data.Dataframe7d, is.EqualTo(hex.EncodeToString(short7d)))
+
+	// the missing channels are carried on the dataframe
+	then.AssertThat(t, data.Missing(), has.Length(6))
+	then.AssertThat(t, data.HasChannel("JackCount"), is.False())
+	then.AssertThat(t, data.HasChannel("CoolantTemp"), is.True())
+
+	// and ignored by the statistics
+	scenario := scenarios.NewScenario()
+	scenario.Memsdata = []*scenarios.MemsFCRData{standard, data}
+	scenario.Count = 2
+
+	for _, channel := range scenario.Stats().Channels {
+		if channel.Channel == "JackCount" {
+			then.AssertThat(t, channel.Min, is.EqualTo(float64(standard.JackCount)))
+			then.AssertThat(t, channel.Mean, is.EqualTo(float64(standard.JackCount)))
+		}
+	}
+}
+
+// registerSwappedTrims registers a variant with the fuel trims swapped for the duration of the test
+func registerSwappedTrims(t *testing.T) {
+	variant := &scenarios.Variant{
+		Name:   "fuel trims swapped",
+		ECUIDs: []string{"12345678"},
+		// long term fuel trim 7dx0B and short term fuel trim 7dx0C are swapped
+		Layout7d: map[int]int{12: 13, 13: 12},
+	}
+
+	scenarios.RegisterVariant(variant)
+	t.Cleanup(func() { scenarios.UnregisterVariant(variant) })
+}
+
+func TestDecodeVariantByECUID(t *testing.T) {
+	registerSwappedTrims(t)
+
+	df80 := "80: 1C 00 00 3C FF 3E FF 63 72 6B 08 10 01 00 00 00 19 90 93 04 E2 00 38 0E 01 10 00 00"
+	df7d := "7D: 20 10 4F FF 7D 00 3C FF FF 01 00 95 64 00 FF 6B FF FF 30 80 7B 99 FF 19 40 1F 00 26 40 34 C0 15"
+	short7d := "7D: 1A 10 4F FF 7D 00 3C FF FF 01 00 95 64 00 FF 6B FF FF 30 80 7B 99 FF 19 40 1F"
+
+	log := strings.Join([]string{
+		"Running command: read-raw",
+		"ECU responded to D0 command with: 12 34 56 78",
+		"",
+		df80,
+		df7d,
+		df80,
+		short7d,
+	}, "\n")
+
+	reader, err := scenarios.NewFrameReader(strings.NewReader(log), utils.ReadMemsFile)
+	then.AssertThat(t, err, is.Nil())
+
+	scenario, err := scenarios.ReadAll(reader)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, scenario.Count, is.EqualTo(2))
+	then.AssertThat(t, scenario.Memsdata[0].LongTermFuelTrim, is.EqualTo(0x64-128))
+	then.AssertThat(t, scenario.Memsdata[0].ShortTermFuelTrim, is.EqualTo(0x95))
+	then.AssertThat(t, scenario.Memsdata[1].LongTermFuelTrim, is.EqualTo(0x64-128))
+	then.AssertThat(t, scenario.Memsdata[1].JackCount, is.EqualTo(0))
+
+	report := reader.(scenarios.ParseReporter).Report()
+	then.AssertThat(t, report.Frames, is.EqualTo(2))
+	then.AssertThat(t, report.Partial, is.EqualTo(1))
+	then.AssertThat(t, report.HasProblems(), is.False())
+
+	// other ECUs use the standard layout
+	d80, _ := hex.DecodeString(strings.ReplaceAll(df80[4:], " ", ""))
+	d7d, _ := hex.DecodeString(strings.ReplaceAll(df7d[4:], " ", ""))
+	then.AssertThat(t, scenarios.FindVariant("", d80, d7d), is.EqualTo(scenarios.StandardVariant))
+}
+
+func TestDecodeReadmemsVariant(t *testing.T) {
+	scenario, err := scenarios.ConvertFile("../data/readmems.data")
+	then.AssertThat(t, err, is.Nil())
+
+	for _, data := range scenario.Memsdata {
+		d80, _ := hex.DecodeString(data.Dataframe80)
+		d7d, _ := hex.DecodeString(data.Dataframe7d)
+
+		variant := scenarios.FindVariant("99000203", d80, d7d)
+		then.AssertThat(t, variant, is.EqualTo(scenarios.ReadmemsVariant))
+
+		decoded, missing := variant.Decode(data.Time, d80, d7d)
+		then.AssertThat(t, missing, is.Empty())
+		then.AssertThat(t, *decoded, is.EqualTo(*scenarios.DecodeDataframes(data.Time, d80, d7d)))
+		then.AssertThat(t, decoded.CoolantTemp, is.EqualTo(data.CoolantTemp))
+	}
+
+	// a short frame from the ECU isn't in the variant's layout
+	d80, d7d := readmemsFrames(t)
+	short7d := append([]byte{}, d7d[:27]...)
+	short7d[1] = 0x1A
+	then.AssertThat(t, scenarios.FindVariant("99000203", d80, short7d), is.EqualTo(scenarios.StandardVariant))
+}
+
+func TestSerialCaptureVariantByECUID(t *testing.T) {
+	registerSwappedTrims(t)
+
+	d80, d7d := readmemsFrames(t)
+	standard := scenarios.DecodeDataframes("08:00:00.000", d80, d7d)
+
+	c := newCapture()
+	c.record([]byte{0xCA, 0xCA, 0x75, 0x75, 0xD0, 0xD0, 0x12, 0x34, 0x56, 0x78})
+	c.pause()
+	c.request(d80)
+	c.request(d7d)
+
+	scenario, err := scenarios.ConvertFile(c.save(t))
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, scenario.Count, is.EqualTo(1))
+
+	// the trims are decoded from the swapped positions
+	then.AssertThat(t, scenario.Memsdata[0].ShortTermFuelTrim, is.EqualTo(standard.LongTermFuelTrim+128))
+	then.AssertThat(t, scenario.Memsdata[0].LongTermFuelTrim, is.EqualTo(standard.ShortTermFuelTrim-128))
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/watcher"
+	"github.com/corbym/gocrest/has"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+)
+
+func TestWatcherConvertsSettledFiles(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "converted")
+
+	data, err := os.ReadFile("../data/memsrosco.txt")
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, os.WriteFile(filepath.Join(dir, "road test.txt"), data, 0644), is.Nil())
+
+	w, err := watcher.NewWatcher(dir, out)
+	then.AssertThat(t, err, is.Nil())
+	w.Formats = []string{scenarios.JSONFormat}
+
+	start := time.Now()
+
+	// the first poll sees the file, it's not converted until it has settled
+	processed, err := w.Poll(start)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, processed, has.Length(0))
+
+	processed, err = w.Poll(start.Add(w.Settle))
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, processed, has.Length(1))
+	then.AssertThat(t, processed[0].Status, is.EqualTo(scenarios.Converted))
+
+	_, err = os.Stat(filepath.Join(out, "road test.txt.output.json"))
+	then.AssertThat(t, err, is.Nil())
+
+	// a restarted watcher reads the ledger and doesn't convert the file again
+	w, err = watcher.NewWatcher(dir, out)
+	then.AssertThat(t, err, is.Nil())
+
+	w.Poll(start)
+	processed, _ = w.Poll(start.Add(w.Settle))
+	then.AssertThat(t, processed, has.Length(0))
+}
+
+func TestWatcherForgetsRemovedFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "road test.txt")
+
+	data, err := os.ReadFile("../data/memsrosco.txt")
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, os.WriteFile(file, data, 0644), is.Nil())
+	info, _ := os.Stat(file)
+
+	w, err := watcher.NewWatcher(dir, t.TempDir())
+	then.AssertThat(t, err, is.Nil())
+
+	start := time.Now()
+	w.Poll(start)
+
+	// the file is removed before it settles
+	then.AssertThat(t, os.Remove(file), is.Nil())
+	processed, err := w.Poll(start.Add(time.Second))
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, processed, has.Length(0))
+
+	// the same file copied back is seen as a new file and has to settle again
+	then.AssertThat(t, os.WriteFile(file, data, 0644), is.Nil())
+	then.AssertThat(t, os.Chtimes(file, info.ModTime(), info.ModTime()), is.Nil())
+
+	processed, err = w.Poll(start.Add(w.Settle))
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, processed, has.Length(0))
+
+	// only an error reading the watched directory stops the watcher
+	then.AssertThat(t, os.RemoveAll(dir), is.Nil())
+	_, err = w.Poll(start.Add(2 * w.Settle))
+	then.AssertThat(t, err, is.Not(is.Nil()))
+}
+
+func TestWatcherRejectsTheWatchedDirectoryAsOutput(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := watcher.NewWatcher(dir, dir)
+	then.AssertThat(t, err, is.Not(is.Nil()))
+
+	_, err = watcher.NewWatcher(dir, filepath.Join(dir, "converted", ".."))
+	then.AssertThat(t, err, is.Not(is.Nil()))
+
+	_, err = watcher.NewWatcher(dir, filepath.Join(dir, "converted"))
+	then.AssertThat(t, err, is.Nil())
+}
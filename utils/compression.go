@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Logs may be compressed with gzip, zstd or bzip2, the compression is detected
+// from the first bytes of the file so compressed logs can also be read from stdin.
+// Archives (zip, tar and compressed tar) contain several logs.
+
+var (
+	gzipMagic  = []byte{0x1F, 0x8B}
+	zstdMagic  = []byte{0x28, 0xB5, 0x2F, 0xFD}
+	bzip2Magic = []byte("BZh")
+	zipMagic   = []byte("PK\x03\x04")
+	tarMagic   = []byte("ustar")
+)
+
+// tarMagicOffset is the position of the magic in the tar header
+const tarMagicOffset = 257
+
+// compressionExtensions are removed from the file names when naming the output
+var compressionExtensions = []string{".gz", ".zst", ".bz2"}
+
+// Decompress returns a reader that decompresses the contents if they're compressed,
+// uncompressed contents are returned unchanged
+func Decompress(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, _ := buffered.Peek(len(zstdMagic))
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(buffered)
+	case bytes.HasPrefix(magic, zstdMagic):
+		// a single threaded decoder runs synchronously so it doesn't need closing
+		return zstd.NewReader(buffered, zstd.WithDecoderConcurrency(1))
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return bzip2.NewReader(buffered), nil
+	}
+
+	return buffered, nil
+}
+
+// TrimCompressionExtension removes the compression extension from the file name
+func TrimCompressionExtension(name string) string {
+	for _, extension := range compressionExtensions {
+		if strings.HasSuffix(strings.ToLower(name), extension) {
+			return name[:len(name)-len(extension)]
+		}
+	}
+
+	return name
+}
+
+// IsArchiveHeader returns true if the (decompressed) header is a zip or tar archive
+func IsArchiveHeader(header []byte) bool {
+	if bytes.HasPrefix(header, zipMagic) {
+		return true
+	}
+
+	return len(header) >= tarMagicOffset+len(tarMagic) &&
+		bytes.Equal(header[tarMagicOffset:tarMagicOffset+len(tarMagic)], tarMagic)
+}
+
+// IsArchive returns true if the file is a zip or tar archive, tar archives may be compressed
+func IsArchive(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	r, err := Decompress(file)
+	if err != nil {
+		return false
+	}
+
+	header := make([]byte, tarMagicOffset+len(tarMagic))
+	n, _ := io.ReadFull(r, header)
+
+	return IsArchiveHeader(header[:n])
+}
+
+// WalkArchive calls the function with the name and contents of each file in the zip or
+// tar archive, the contents are decompressed if the file is compressed
+func WalkArchive(path string, fn func(name string, r io.Reader) error) error {
+	if archive, err := zip.OpenReader(path); err == nil {
+		defer archive.Close()
+		return walkZip(archive, fn)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r, err := Decompress(file)
+	if err != nil {
+		return err
+	}
+
+	return walkTar(tar.NewReader(r), fn)
+}
+
+func walkZip(archive *zip.ReadCloser, fn func(name string, r io.Reader) error) error {
+	for _, member := range archive.File {
+		if member.FileInfo().IsDir() {
+			continue
+		}
+
+		r, err := member.Open()
+		if err != nil {
+			return err
+		}
+
+		err = walkMember(member.Name, r, fn)
+		r.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func walkTar(archive *tar.Reader, fn func(name string, r io.Reader) error) error {
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if err = walkMember(header.Name, archive, fn); err != nil {
+			return err
+		}
+	}
+}
+
+// walkMember decompresses the member if it's compressed
+func walkMember(name string, r io.Reader, fn func(name string, r io.Reader) error) error {
+	decompressed, err := Decompress(r)
+	if err != nil {
+		return err
+	}
+
+	return fn(name, decompressed)
+}
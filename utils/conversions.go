@@ -2,8 +2,10 @@ package utils
 
 import (
 	"encoding/csv"
+	"fmt"
 	"io"
 	"math"
+	"time"
 
 	"github.com/gocarina/gocsv"
 )
@@ -34,3 +36,20 @@ func NewLineSkipDecoder(r io.Reader, LinesToSkip int) (gocsv.SimpleDecoder, erro
 	reader.FieldsPerRecord = 0
 	return gocsv.NewSimpleDecoderFromCSVReader(reader), nil
 }
+
+// timestamp layouts used by the log files, fractional seconds are accepted by all layouts
+var timestampLayouts = []string{"15:04:05", "04:05"}
+
+// TimestampToDuration converts a dataframe time into the duration since midnight
+func TimestampToDuration(timestamp string) (time.Duration, error) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, timestamp); err == nil {
+			return time.Duration(t.Hour())*time.Hour +
+				time.Duration(t.Minute())*time.Minute +
+				time.Duration(t.Second())*time.Second +
+				time.Duration(t.Nanosecond()), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unrecognised timestamp '%s'", timestamp)
+}
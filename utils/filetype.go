@@ -2,6 +2,7 @@ package utils
 
 import (
 	"bufio"
+	"io"
 	"os"
 	"strings"
 )
@@ -17,21 +18,51 @@ const (
 	MemsDiagFile = "memsdiag"
 	// MemsFCRFile that's mine
 	MemsFCRFile = "memsfcr"
+	// SerialCaptureFile raw bytes sniffed from the serial line
+	SerialCaptureFile = "serialcapture"
+	// ArchiveFile a zip or tar archive of logs
+	ArchiveFile = "archive"
 	// Unknown eh?
 	Unknown = "unknown"
 )
 
+// SerialCaptureHeader identifies a raw serial capture
+const SerialCaptureHeader = "MEMSCAP1"
+
 // GetFileType determines the file type
 func GetFileType(path string) string {
 	file, err := os.Open(path)
 
 	if err != nil {
 		LogE.Printf("unable to open %s", err)
+		return Unknown
 	}
 
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	if IsArchive(path) {
+		return ArchiveFile
+	}
+
+	r, err := Decompress(file)
+	if err != nil {
+		return Unknown
+	}
+
+	return DetectFileType(r)
+}
+
+// DetectFileType determines the file type from the contents, a prefix
+// of the file containing the header is enough
+func DetectFileType(r io.Reader) string {
+	buffered := bufio.NewReader(r)
+
+	// serial captures are binary so check the header before reading lines
+	if header, _ := buffered.Peek(len(SerialCaptureHeader)); string(header) == SerialCaptureHeader {
+		return SerialCaptureFile
+	}
+
+	scanner := bufio.NewScanner(buffered)
 
 	for scanner.Scan() {
 		line := scanner.Text()
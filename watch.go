@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+	"github.com/andrewdjackson/memscene/watcher"
+)
+
+// watch a directory and convert the logs as they appear
+//
+// memscene watch [-out converted/] [-formats csv,json] <dir>
+func watch(args []string) {
+	var out string
+	var formats string
+	var interval time.Duration
+	var settle time.Duration
+
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	flags.StringVar(&out, "out", "converted", "destination directory")
+	flags.StringVar(&formats, "formats", "csv", "formats to write, comma separated (csv, json, ndjson)")
+	flags.DurationVar(&interval, "interval", 2*time.Second, "time between checks of the directory")
+	flags.DurationVar(&settle, "settle", 5*time.Second, "time a file must stop growing before it's converted")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Println("Usage of ./memscene watch [-out <directory>] <directory>")
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+
+	w, err := watcher.NewWatcher(flags.Arg(0), out)
+	if err != nil {
+		utils.LogE.Fatalf("unable to watch %s %s", flags.Arg(0), err)
+	}
+
+	for _, format := range strings.Split(formats, ",") {
+		format = strings.TrimSpace(format)
+
+		if !isFormat(format) {
+			utils.LogE.Fatalf("unknown format '%s'", format)
+		}
+
+		w.Formats = append(w.Formats, format)
+	}
+
+	w.Interval = interval
+	w.Settle = settle
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err = w.Run(ctx); err != nil {
+		utils.LogE.Fatalf("watch stopped %s", err)
+	}
+}
+
+func isFormat(format string) bool {
+	for _, f := range scenarios.Formats {
+		if f == format {
+			return true
+		}
+	}
+
+	return false
+}
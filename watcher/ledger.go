@@ -0,0 +1,82 @@
+package watcher
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gocarina/gocsv"
+)
+
+// Ledger records the files that have been processed so they aren't converted again
+// after a restart. The ledger is a CSV file in the format:
+//
+// file,size,modified,processed,status,count,error
+type Ledger struct {
+	path    string
+	mutex   sync.Mutex
+	entries []*LedgerEntry
+}
+
+// LedgerEntry is a processed file, a file is processed again if its size or
+// modification time changes
+type LedgerEntry struct {
+	File      string    `csv:"file"`
+	Size      int64     `csv:"size"`
+	Modified  time.Time `csv:"modified"`
+	Processed time.Time `csv:"processed"`
+	Status    string    `csv:"status"`
+	Count     int       `csv:"count"`
+	Error     string    `csv:"error"`
+}
+
+// LoadLedger reads the ledger, a missing ledger is empty
+func LoadLedger(path string) (*Ledger, error) {
+	ledger := &Ledger{path: path}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ledger, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if err = gocsv.Unmarshal(file, &ledger.entries); err != nil {
+		return nil, err
+	}
+
+	return ledger, nil
+}
+
+// IsProcessed returns true if the file has been processed since it was last changed
+func (ledger *Ledger) IsProcessed(file string, info os.FileInfo) bool {
+	ledger.mutex.Lock()
+	defer ledger.mutex.Unlock()
+
+	for _, entry := range ledger.entries {
+		if entry.File == file && entry.Size == info.Size() && entry.Modified.Equal(info.ModTime()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Record adds the entry and saves the ledger
+func (ledger *Ledger) Record(entry *LedgerEntry) error {
+	ledger.mutex.Lock()
+	defer ledger.mutex.Unlock()
+
+	ledger.entries = append(ledger.entries, entry)
+
+	file, err := os.Create(ledger.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return gocsv.Marshal(&ledger.entries, file)
+}
@@ -0,0 +1,240 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/andrewdjackson/memscene/scenarios"
+	"github.com/andrewdjackson/memscene/utils"
+)
+
+// LedgerFile is the name of the ledger in the output directory
+const LedgerFile = "memscene-ledger.csv"
+
+// Watcher polls a directory for new or changed log files and converts them once
+// they have stopped growing
+type Watcher struct {
+	// Dir watched for log files
+	Dir string
+	// Out directory for the converted files
+	Out string
+	// Formats to write, the MemsFCR csv format is always written
+	Formats []string
+	// Interval between polls of the directory
+	Interval time.Duration
+	// Settle time the file must be unchanged before it's converted
+	Settle time.Duration
+	ledger *Ledger
+	seen   map[string]*pending
+}
+
+// pending is a file waiting to stop growing
+type pending struct {
+	size     int64
+	modified time.Time
+	since    time.Time
+}
+
+// NewWatcher creates a watcher, the ledger is kept in the output directory. The output
+// directory can be inside the watched directory but can't be the watched directory
+func NewWatcher(dir string, out string) (*Watcher, error) {
+	root, _ := filepath.Abs(dir)
+	output, _ := filepath.Abs(out)
+
+	if root == output {
+		return nil, fmt.Errorf("the output directory %s is the watched directory, use a separate or sub directory", out)
+	}
+
+	if err := os.MkdirAll(out, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	ledger, err := LoadLedger(filepath.Join(out, LedgerFile))
+	if err != nil {
+		return nil, err
+	}
+
+	watcher := &Watcher{}
+	watcher.Dir = dir
+	watcher.Out = out
+	watcher.Formats = []string{scenarios.CSVFormat}
+	watcher.Interval = 2 * time.Second
+	watcher.Settle = 5 * time.Second
+	watcher.ledger = ledger
+	watcher.seen = make(map[string]*pending)
+
+	return watcher, nil
+}
+
+// Run polls the directory until the context is cancelled
+func (watcher *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(watcher.Interval)
+	defer ticker.Stop()
+
+	utils.LogI.Printf("watching %s, converting into %s", watcher.Dir, watcher.Out)
+
+	for {
+		if _, err := watcher.Poll(time.Now()); err != nil {
+			return err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Poll checks the directory and converts the files that have been unchanged for the
+// settle time, returns the ledger entries of the files processed. Files that can't be
+// read are skipped, only an error reading the watched directory is returned
+func (watcher *Watcher) Poll(now time.Time) ([]*LedgerEntry, error) {
+	var processed []*LedgerEntry
+
+	out, _ := filepath.Abs(watcher.Out)
+	found := make(map[string]bool)
+
+	err := filepath.WalkDir(watcher.Dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == watcher.Dir {
+				return err
+			}
+
+			// the file may have been removed since the directory was read
+			utils.LogW.Printf("skipping %s %s", path, err)
+
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		// don't watch the converted files if the output is inside the watched directory
+		if abs, _ := filepath.Abs(path); entry.IsDir() && abs == out {
+			return filepath.SkipDir
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			utils.LogW.Printf("skipping %s %s", path, err)
+			return nil
+		}
+
+		found[path] = true
+
+		if watcher.ledger.IsProcessed(path, info) || !watcher.isSettled(path, info, now) {
+			return nil
+		}
+
+		result := watcher.convert(path, info, now)
+		delete(watcher.seen, path)
+
+		if err := watcher.ledger.Record(result); err != nil {
+			return err
+		}
+
+		processed = append(processed, result)
+
+		return nil
+	})
+
+	// forget the files that have gone before they settled
+	for path := range watcher.seen {
+		if err == nil && !found[path] {
+			delete(watcher.seen, path)
+		}
+	}
+
+	return processed, err
+}
+
+// isSettled returns true once the file size and modification time have been
+// unchanged for the settle time
+func (watcher *Watcher) isSettled(path string, info os.FileInfo, now time.Time) bool {
+	p, ok := watcher.seen[path]
+
+	if !ok || p.size != info.Size() || !p.modified.Equal(info.ModTime()) {
+		watcher.seen[path] = &pending{size: info.Size(), modified: info.ModTime(), since: now}
+		return false
+	}
+
+	return now.Sub(p.since) >= watcher.Settle
+}
+
+// convert the file into each of the formats
+func (watcher *Watcher) convert(path string, info os.FileInfo, now time.Time) *LedgerEntry {
+	entry := &LedgerEntry{File: path, Size: info.Size(), Modified: info.ModTime(), Processed: now}
+
+	if utils.GetFileType(path) == utils.Unknown {
+		entry.Status = scenarios.Skipped
+		return entry
+	}
+
+	relative, _ := filepath.Rel(watcher.Dir, path)
+	directory, filename := filepath.Split(relative)
+
+	formats := append([]string{scenarios.CSVFormat}, watcher.Formats...)
+	written := map[string]bool{}
+
+	var count int
+	var err error
+
+	// each format is streamed from the log so the whole log isn't held in memory
+	for _, format := range formats {
+		if written[format] {
+			continue
+		}
+
+		if count, err = watcher.convertTo(path, directory, filename, format); err != nil {
+			break
+		}
+
+		written[format] = true
+	}
+
+	if err != nil {
+		utils.LogW.Printf("unable to convert %s %s", path, err)
+		entry.Status = scenarios.Failed
+		entry.Error = err.Error()
+		return entry
+	}
+
+	utils.LogI.Printf("converted %s, %d dataframes", path, count)
+
+	entry.Status = scenarios.Converted
+	entry.Count = count
+
+	return entry
+}
+
+// convertTo converts the log, or each log in an archive, into the format
+func (watcher *Watcher) convertTo(path string, directory string, filename string, format string) (int, error) {
+	out := filepath.Join(watcher.Out, directory)
+
+	if !utils.IsArchive(path) {
+		return scenarios.ConvertFileTo(path, filepath.Join(out, scenarios.OutputName(filename, format)), format)
+	}
+
+	results, err := scenarios.ConvertArchive(path, out, format)
+	count := 0
+
+	for _, result := range results {
+		if result.Err != nil && err == nil {
+			err = result.Err
+		}
+
+		count += result.Count
+	}
+
+	return count, err
+}